@@ -0,0 +1,7 @@
+-- USER ROLES
+ALTER TABLE users
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'member'
+    CHECK (role IN ('admin', 'member'));
+
+-- Seeded admin so assets and users can be managed out of the box
+UPDATE users SET role = 'admin' WHERE id = 'u1';
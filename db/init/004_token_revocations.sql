@@ -0,0 +1,16 @@
+-- TOKEN REVOCATIONS
+-- Individually revoked access tokens, kept until the token would have expired.
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id TEXT,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+-- Access tokens for user_id issued before revoked_before are rejected.
+CREATE TABLE user_token_revocations (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    revoked_before TIMESTAMP NOT NULL
+);
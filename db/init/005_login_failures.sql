@@ -0,0 +1,9 @@
+-- LOGIN FAILURES
+-- Failed login counters keyed by 'user:<id>' or 'ip:<address>'. A key is
+-- rejected without checking the password while locked_until is in the future.
+CREATE TABLE login_failures (
+    key TEXT PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT now(),
+    locked_until TIMESTAMP
+);
@@ -0,0 +1,20 @@
+-- TWO-FACTOR AUTHENTICATION
+-- TOTP secret per user. The secret is only active once enabled_at is set by
+-- POST /me/2fa/verify; last_used_step blocks replaying a code.
+CREATE TABLE user_totp (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret TEXT NOT NULL,
+    enabled_at TIMESTAMP,
+    last_used_step BIGINT,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+-- Single-use recovery codes. Only the SHA-256 hash is stored.
+CREATE TABLE user_recovery_codes (
+    code_hash TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX user_recovery_codes_user_id_idx ON user_recovery_codes (user_id);
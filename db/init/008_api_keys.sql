@@ -0,0 +1,18 @@
+-- API KEYS
+-- Long-lived keys for service-to-service access. Only the SHA-256 hash of the
+-- key is stored; prefix is the non-secret start of the key, shown in listings
+-- so users can tell keys apart. scopes is a space-separated list.
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL UNIQUE,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
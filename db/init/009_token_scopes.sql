@@ -0,0 +1,4 @@
+-- TOKEN SCOPES
+-- Space-separated scopes granted at login, carried over when a refresh token
+-- is rotated. Empty means every scope allowed for the user's role.
+ALTER TABLE refresh_tokens ADD COLUMN scope TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,29 @@
+-- OIDC IDENTITIES
+-- Links an account at an external OpenID Connect provider, identified by the
+-- issuer and its stable subject, to a local user. Users provisioned on first
+-- SSO login have an empty password_hash and can only sign in through SSO
+-- until they set a password.
+CREATE TABLE user_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT,
+    created_at TIMESTAMP DEFAULT now(),
+    last_login_at TIMESTAMP,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- OIDC LOGIN STATES
+-- Pending authorization requests started by GET /auth/oidc/login, consumed by
+-- the callback. Only the SHA-256 hash of the state is stored. link_user_id is
+-- set when a signed-in user links a provider account to their user.
+CREATE TABLE oidc_login_states (
+    state_hash TEXT PRIMARY KEY,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    link_user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT now()
+);
@@ -0,0 +1,4 @@
+-- USER STATUS
+-- Disabled users cannot log in and their tokens and API keys are rejected.
+-- NULL means the user is active.
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
@@ -0,0 +1,15 @@
+-- USER PROFILE
+-- Optional profile fields. Emails are unique regardless of case and can be
+-- used instead of the ID to log in.
+ALTER TABLE users
+    ADD COLUMN email TEXT,
+    ADD COLUMN display_name TEXT,
+    ADD COLUMN avatar_url TEXT,
+    ADD COLUMN locale TEXT,
+    ADD COLUMN updated_at TIMESTAMP DEFAULT now(),
+    ADD COLUMN last_login_at TIMESTAMP;
+
+CREATE UNIQUE INDEX users_email_lower_idx ON users (lower(email));
+
+UPDATE users SET email = 'alice@example.com' WHERE id = 'u1';
+UPDATE users SET email = 'bob@example.com' WHERE id = 'u2';
@@ -0,0 +1,9 @@
+-- EMAIL VERIFICATION
+-- Users with an unverified email cannot change their favourites.
+-- email_verification_sent_at rate limits verification emails.
+ALTER TABLE users
+    ADD COLUMN email_verified_at TIMESTAMP,
+    ADD COLUMN email_verification_sent_at TIMESTAMP;
+
+-- Existing addresses predate verification, so trust them
+UPDATE users SET email_verified_at = now() WHERE email IS NOT NULL;
@@ -0,0 +1,16 @@
+-- INVITATIONS
+-- Single-use codes issued by admins to register with, required when public
+-- registration is turned off. Only the SHA-256 hash of the code is stored.
+-- When email is set the invitation can only be used with that address.
+CREATE TABLE invitations (
+    id TEXT PRIMARY KEY,
+    code_hash TEXT NOT NULL UNIQUE,
+    email TEXT,
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member')),
+    created_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    used_by TEXT REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT now()
+);
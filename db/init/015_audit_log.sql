@@ -0,0 +1,14 @@
+-- AUDIT LOG
+-- Actions taken by one user on behalf of another, e.g. an admin
+-- impersonating a customer. No foreign keys, so records outlive the users.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    detail TEXT,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, created_at);
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id, created_at);
@@ -0,0 +1,13 @@
+-- LOGIN HISTORY
+-- One row per successful login, kept with the user and included in their
+-- data export.
+CREATE TABLE login_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    method TEXT NOT NULL,
+    ip_address TEXT,
+    user_agent TEXT,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+CREATE INDEX login_history_user_id_idx ON login_history (user_id, created_at);
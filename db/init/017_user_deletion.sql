@@ -0,0 +1,7 @@
+-- ACCOUNT DELETION
+-- Users deleting their account are only marked deleted. They can restore
+-- it during a grace period, after which a background job removes the user
+-- and, through the cascades, their favourites and other data.
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
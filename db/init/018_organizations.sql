@@ -0,0 +1,34 @@
+-- ORGANIZATIONS
+-- Customers are companies: users belong to any number of organizations
+-- with a role in each. Assets may belong to an organization, in which case
+-- only its members see them when browsing within that organization.
+CREATE TABLE organizations (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT now(),
+    updated_at TIMESTAMP DEFAULT now()
+);
+
+CREATE TABLE organization_members (
+    org_id TEXT NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member')),
+    created_at TIMESTAMP DEFAULT now(),
+    PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX organization_members_user_id_idx ON organization_members (user_id);
+
+ALTER TABLE assets ADD COLUMN org_id TEXT REFERENCES organizations(id) ON DELETE CASCADE;
+
+CREATE INDEX assets_org_id_idx ON assets (org_id);
+
+-- The active organization of a login is carried over when refreshing
+ALTER TABLE refresh_tokens ADD COLUMN org_id TEXT NOT NULL DEFAULT '';
+
+-- SEED DATA
+INSERT INTO organizations (id, name) VALUES ('acme', 'Acme');
+
+INSERT INTO organization_members (org_id, user_id, role) VALUES
+('acme', 'u1', 'owner'),
+('acme', 'u2', 'member');
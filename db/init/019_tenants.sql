@@ -0,0 +1,41 @@
+-- TENANTS
+-- One deployment serves several customers. Users, assets, favourites,
+-- organizations and invitations belong to a tenant, and repositories only
+-- ever read or write the rows of the tenant of the request. Existing rows
+-- belong to the 'default' tenant. The composite foreign keys make the
+-- database refuse rows that link data of different tenants.
+CREATE TABLE tenants (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT now()
+);
+
+INSERT INTO tenants (id, name) VALUES ('default', 'Default');
+
+ALTER TABLE users ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id);
+ALTER TABLE users ADD CONSTRAINT users_tenant_id_id_key UNIQUE (tenant_id, id);
+
+-- Emails are unique within a tenant only
+DROP INDEX users_email_lower_idx;
+CREATE UNIQUE INDEX users_email_lower_idx ON users (tenant_id, lower(email));
+
+ALTER TABLE organizations ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id);
+ALTER TABLE organizations ADD CONSTRAINT organizations_tenant_id_id_key UNIQUE (tenant_id, id);
+
+ALTER TABLE organization_members ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE organization_members
+    ADD FOREIGN KEY (tenant_id, org_id) REFERENCES organizations (tenant_id, id) ON DELETE CASCADE,
+    ADD FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE;
+
+ALTER TABLE assets ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id);
+ALTER TABLE assets ADD CONSTRAINT assets_tenant_id_id_key UNIQUE (tenant_id, id);
+ALTER TABLE assets ADD FOREIGN KEY (tenant_id, org_id) REFERENCES organizations (tenant_id, id) ON DELETE CASCADE;
+
+CREATE INDEX assets_tenant_id_idx ON assets (tenant_id, created_at);
+
+ALTER TABLE favourites ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE favourites
+    ADD FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE,
+    ADD FOREIGN KEY (tenant_id, asset_id) REFERENCES assets (tenant_id, id) ON DELETE CASCADE;
+
+ALTER TABLE invitations ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default' REFERENCES tenants(id);
@@ -0,0 +1,26 @@
+-- ROW-LEVEL SECURITY
+-- Defence in depth behind the WHERE clauses of the repositories: Postgres
+-- itself only shows a user their own favourites. Repositories run these
+-- queries in a transaction that sets app.current_user and app.current_role
+-- from the access token; without them no favourite is visible.
+--
+-- Superusers and BYPASSRLS roles skip the policies, so the API connects as
+-- the dashboard_app role instead of the owner of the tables. Foreign key
+-- cascades, such as the purge of deleted accounts, run as the owner.
+CREATE ROLE dashboard_app LOGIN PASSWORD 'dashboard_app' NOSUPERUSER NOBYPASSRLS;
+
+GRANT USAGE ON SCHEMA public TO dashboard_app;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO dashboard_app;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO dashboard_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO dashboard_app;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO dashboard_app;
+
+ALTER TABLE favourites ENABLE ROW LEVEL SECURITY;
+ALTER TABLE favourites FORCE ROW LEVEL SECURITY;
+
+-- Admins may manage the favourites of anyone, as in the handlers
+CREATE POLICY favourites_current_user ON favourites
+    USING (
+        user_id = current_setting('app.current_user', true)
+        OR current_setting('app.current_role', true) = 'admin'
+    );
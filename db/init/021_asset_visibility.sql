@@ -0,0 +1,28 @@
+-- ASSET OWNERSHIP AND VISIBILITY
+-- Assets record the user who created them and who may see them: only the
+-- creator (private), the members of the owning organization (org), or every
+-- user of the tenant (public). Admins see every asset. Existing assets have
+-- no creator and stay public.
+ALTER TABLE assets
+    ADD COLUMN created_by TEXT,
+    ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('private', 'org', 'public')),
+    ADD CONSTRAINT assets_org_visibility_check CHECK (visibility <> 'org' OR org_id IS NOT NULL),
+    ADD FOREIGN KEY (tenant_id, created_by) REFERENCES users (tenant_id, id) ON DELETE SET NULL (created_by);
+
+CREATE INDEX assets_created_by_idx ON assets (created_by);
+
+-- Row-level security on top of the visibility checks of the repositories,
+-- with the same app.current_user and app.current_role as favourites
+ALTER TABLE assets ENABLE ROW LEVEL SECURITY;
+ALTER TABLE assets FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY assets_visible ON assets
+    USING (
+        visibility = 'public'
+        OR created_by = current_setting('app.current_user', true)
+        OR (visibility = 'org' AND org_id IN (
+            SELECT org_id FROM organization_members
+            WHERE user_id = current_setting('app.current_user', true)
+        ))
+        OR current_setting('app.current_role', true) = 'admin'
+    );
@@ -0,0 +1,4 @@
+-- PASSWORD RESET THROTTLE
+-- password_reset_sent_at rate limits reset emails, like
+-- email_verification_sent_at does for verification emails.
+ALTER TABLE users ADD COLUMN password_reset_sent_at TIMESTAMP;
@@ -0,0 +1,5 @@
+-- OIDC SESSION MODE
+-- session_mode remembers whether the login was started for a browser
+-- session ('cookie') or for bearer tokens (''), so the callback can answer
+-- the same way POST /login does.
+ALTER TABLE oidc_login_states ADD COLUMN session_mode TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+// accountDeletionGracePeriod is how long a deleted account can be restored
+// before the purge job removes it for good.
+var accountDeletionGracePeriod = 30 * 24 * time.Hour
+
+func init() {
+	// Deleted accounts can be restored for 30 days, e.g. ACCOUNT_DELETION_GRACE_PERIOD=168h
+	if d, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD")); err == nil && d >= 0 {
+		accountDeletionGracePeriod = d
+	}
+}
+
+// EnableAccountPurge permanently removes accounts of every tenant deleted more
+// than accountDeletionGracePeriod ago, every interval until ctx is cancelled.
+func EnableAccountPurge(ctx context.Context, db *sql.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				tenants, err := repositories.ListTenants(ctx, db)
+				if err != nil {
+					log.Printf("failed to list tenants: %v", err)
+					continue
+				}
+
+				cutoff := time.Now().Add(-accountDeletionGracePeriod)
+				for _, tenant := range tenants {
+					purged, err := repositories.PurgeDeletedUsers(repositories.WithTenant(ctx, tenant), db, cutoff)
+					if err != nil {
+						log.Printf("failed to purge deleted accounts of tenant %s: %v", tenant, err)
+					} else if purged > 0 {
+						log.Printf("purged %d deleted accounts of tenant %s", purged, tenant)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// checkAccountDeleted lets logins of deleted accounts through only when they
+// ask to restore the account and it still can be. Otherwise it writes a 403
+// and returns false.
+func checkAccountDeleted(w http.ResponseWriter, user *models.User, restore bool) bool {
+	if user.DeletedAt == nil {
+		return true
+	}
+
+	if time.Since(*user.DeletedAt) >= accountDeletionGracePeriod {
+		http.Error(w, "Account has been deleted", http.StatusForbidden)
+		return false
+	}
+	if !restore {
+		http.Error(w, "Account is scheduled for deletion, log in with restore to cancel it", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// restoreAccount cancels the deletion of user, if any, once a login has
+// passed every check.
+func restoreAccount(r *http.Request, db *sql.DB, user *models.User) error {
+	if user.DeletedAt == nil {
+		return nil
+	}
+
+	if err := repositories.RestoreUser(r.Context(), db, user.ID, time.Now().Add(-accountDeletionGracePeriod)); err != nil {
+		return err
+	}
+	user.DeletedAt = nil
+
+	return nil
+}
+
+// DeleteMe schedules the caller's account for deletion: DELETE /me
+// Logins stop working and every token is revoked right away. The account is
+// removed for good after accountDeletionGracePeriod unless it is restored by
+// logging in with "restore": true.
+func DeleteMe(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if claims.APIKeyID != "" {
+			http.Error(w, "API keys cannot delete the account", http.StatusForbidden)
+			return
+		}
+
+		if rejectImpersonation(w, r) {
+			return
+		}
+
+		if !requireFullScope(w, r) {
+			return
+		}
+
+		deletedAt, err := repositories.SoftDeleteUser(r.Context(), db.(*sql.DB), claims.Subject)
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to delete account: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if err := revokeAllUserTokens(r.Context(), db.(*sql.DB), claims.Subject); err != nil {
+			http.Error(w, "Failed to revoke tokens: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := r.Cookie(sessionCookie); err == nil {
+			clearSessionCookies(w)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":          claims.Subject,
+			"deleted_at":  deletedAt,
+			"purge_after": deletedAt.Add(accountDeletionGracePeriod),
+		})
+	}
+}
+
+// RestoreUser cancels the pending deletion of an account:
+// POST /users/{userID}/restore
+func RestoreUser(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !requireScope(w, r, models.ScopeUsersAdmin) {
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[0] != "users" || parts[2] != "restore" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		userID := parts[1]
+
+		err := repositories.RestoreUser(r.Context(), db.(*sql.DB), userID, time.Now().Add(-accountDeletionGracePeriod))
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			http.Error(w, "No restorable deletion for this user", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to restore user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"id": userID, "restored": true})
+	}
+}
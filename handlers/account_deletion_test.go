@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+// deletedUserRow returns a users row for a user who deleted their account
+// at deletedAt.
+func deletedUserRow(id, name, passwordHash string, deletedAt time.Time) []driver.Value {
+	row := userRow(id, name, passwordHash, models.RoleMember)
+	row[13] = deletedAt
+	return row
+}
+
+func TestDeleteMe_SoftDeletesAndRevokesTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE users").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}).AddRow(time.Now()))
+	mock.ExpectExec("INSERT INTO user_token_revocations").
+		WithArgs("u2", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens").
+		WithArgs("u2", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := withSubject(httptest.NewRequest(http.MethodDelete, "/me", nil), "u2")
+	rec := httptest.NewRecorder()
+
+	MeRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"purge_after"`) {
+		t.Fatalf("expected purge_after in response: %s", rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLogin_DeletedAccount(t *testing.T) {
+	hash, err := passwordHasher.Hash("bob123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	cases := map[string]struct {
+		deletedAt time.Time
+		body      string
+	}{
+		"without restore":    {time.Now().Add(-time.Hour), `{"id":"u2","password":"bob123"}`},
+		"after grace period": {time.Now().Add(-accountDeletionGracePeriod - time.Hour), `{"id":"u2","password":"bob123","restore":true}`},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New error: %v", err)
+			}
+			defer db.Close()
+
+			expectNoLockout(mock, "u2")
+			mock.ExpectQuery("SELECT id, name, password_hash").
+				WithArgs("u2", "default").
+				WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(deletedUserRow("u2", "Bob", hash, tc.deletedAt)...))
+
+			rec := httptest.NewRecorder()
+			Login(db).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(tc.body)))
+
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestLogin_RestoresDeletedAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := passwordHasher.Hash("bob123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	expectNoLockout(mock, "u2")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(deletedUserRow("u2", "Bob", hash, time.Now().Add(-time.Hour))...))
+	expectNoTwoFactor(mock, "u2")
+	mock.ExpectExec("SET deleted_at = NULL").
+		WithArgs("u2", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectRecordLogin(mock, "u2")
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u2", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"id":"u2","password":"bob123","restore":true}`
+	rec := httptest.NewRecorder()
+	Login(db).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRestoreUser_NothingToRestore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("SET deleted_at = NULL").
+		WithArgs("u2", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/users/u2/restore", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	RestoreUser(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
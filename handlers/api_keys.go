@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+// apiKeyPrefix starts every API key so leaked keys are easy to spot, e.g. by
+// secret scanners.
+const apiKeyPrefix = "pgc_"
+
+// apiKeyTouchResolution limits how often last_used_at is written for a key.
+const apiKeyTouchResolution = time.Minute
+
+// apiKeyDB is nil until EnableAPIKeys is called, in which case AuthMiddleware
+// refuses API keys.
+var apiKeyDB *sql.DB
+
+// EnableAPIKeys lets AuthMiddleware authenticate requests with API keys
+// stored in db.
+func EnableAPIKeys(db *sql.DB) {
+	apiKeyDB = db
+}
+
+type apiKeyCreatedResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// generateAPIKey returns a new key of the form pgc_<prefix>_<secret> and its
+// prefix, which is stored to identify the key without revealing it.
+func generateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	prefix = apiKeyPrefix + hex.EncodeToString(b)
+
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	return prefix + "_" + secret, prefix, nil
+}
+
+// apiKeyFromRequest returns the API key sent in the X-API-Key header or as
+// "Authorization: ApiKey <key>", or "" when there is none.
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	if scheme, key, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && scheme == "ApiKey" {
+		return key
+	}
+
+	return ""
+}
+
+// authenticateAPIKey returns claims for the user owning key, limited to the
+// key's scopes and carrying the owner's tenant, or nil when the key is
+// unknown, revoked or expired or its owner is disabled.
+func authenticateAPIKey(ctx context.Context, key string) (*Claims, error) {
+	apiKey, tenantID, err := repositories.GetActiveAPIKeyByHash(ctx, apiKeyDB, hashOpaqueToken(key))
+	if err != nil || apiKey == nil {
+		return nil, err
+	}
+	ctx = repositories.WithTenant(ctx, tenantID)
+
+	user, err := repositories.GetUserByID(ctx, apiKeyDB, apiKey.UserID)
+	if err != nil || user == nil || user.DisabledAt != nil || user.DeletedAt != nil {
+		return nil, err
+	}
+
+	if err := repositories.TouchAPIKey(ctx, apiKeyDB, apiKey.ID, apiKeyTouchResolution); err != nil {
+		println("Error updating API key last use:", err.Error())
+	}
+
+	return &Claims{
+		Role:     user.Role,
+		Scope:    strings.Join(apiKey.Scopes, " "),
+		TenantID: tenantID,
+		APIKeyID: apiKey.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: user.ID,
+		},
+	}, nil
+}
+
+// validateAPIKeyScopes checks that scopes is a non-empty list of known scopes
+// that owner is allowed to hold.
+func validateAPIKeyScopes(scopes []string, owner *models.User) error {
+	if len(scopes) == 0 {
+		return errors.New("At least one scope required")
+	}
+
+	_, err := grantScope(strings.Join(scopes, " "), owner.Role)
+	return err
+}
+
+// APIKeysRouter manages the API keys of a user. Keys can only be managed with
+// a user session, not with another API key.
+func APIKeysRouter(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Expected paths:
+		// GET /users/{userID}/api-keys - List keys
+		// POST /users/{userID}/api-keys - Create a key
+		// GET /users/{userID}/api-keys/{keyID} - Get a key
+		// PATCH /users/{userID}/api-keys/{keyID} - Rename a key or change its scopes
+		// DELETE /users/{userID}/api-keys/{keyID} - Revoke a key
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		if len(parts) < 3 || parts[0] != "users" || parts[2] != "api-keys" {
+			http.NotFound(w, r)
+			return
+		}
+
+		userID := parts[1]
+
+		if !canAccessUser(r, userID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if claims, ok := claimsFromContext(r.Context()); ok && claims.APIKeyID != "" {
+			http.Error(w, "API keys cannot manage API keys", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if len(parts) == 3 {
+				ListAPIKeys(db, userID)(w, r)
+				return
+			}
+			if len(parts) == 4 {
+				GetAPIKey(db, userID, parts[3])(w, r)
+				return
+			}
+
+		case http.MethodPost:
+			if len(parts) == 3 {
+				CreateAPIKey(db, userID)(w, r)
+				return
+			}
+
+		case http.MethodPatch:
+			if len(parts) == 4 {
+				UpdateAPIKey(db, userID, parts[3])(w, r)
+				return
+			}
+
+		case http.MethodDelete:
+			if len(parts) == 4 {
+				RevokeAPIKey(db, userID, parts[3])(w, r)
+				return
+			}
+		}
+
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func ListAPIKeys(db DB, userID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := repositories.ListAPIKeys(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch API keys: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(keys)
+	}
+}
+
+func GetAPIKey(db DB, userID, keyID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := repositories.GetAPIKey(r.Context(), db.(*sql.DB), userID, keyID)
+		if err != nil {
+			http.Error(w, "Failed to fetch API key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if key == nil {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(key)
+	}
+}
+
+// CreateAPIKey creates a key for userID. The key itself is only returned in
+// this response; afterwards only its prefix is shown.
+func CreateAPIKey(db DB, userID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rejectImpersonation(w, r) {
+			return
+		}
+
+		var input struct {
+			Name      string     `json:"name"`
+			Scopes    []string   `json:"scopes"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if input.Name == "" {
+			http.Error(w, "Name required", http.StatusBadRequest)
+			return
+		}
+
+		if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+			http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+			return
+		}
+
+		owner, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "failed to verify user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if owner == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		slices.Sort(input.Scopes)
+		input.Scopes = slices.Compact(input.Scopes)
+		if err := validateAPIKeyScopes(input.Scopes, owner); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// A key can never do more than the credential creating it
+		for _, scope := range input.Scopes {
+			if !requireScope(w, r, scope) {
+				return
+			}
+		}
+
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			http.Error(w, "Failed to generate API key", http.StatusInternalServerError)
+			return
+		}
+
+		secret, prefix, err := generateAPIKey()
+		if err != nil {
+			http.Error(w, "Failed to generate API key", http.StatusInternalServerError)
+			return
+		}
+
+		key, err := repositories.CreateAPIKey(r.Context(), db.(*sql.DB), models.APIKey{
+			ID:        hex.EncodeToString(id),
+			UserID:    userID,
+			Name:      input.Name,
+			Prefix:    prefix,
+			KeyHash:   hashOpaqueToken(secret),
+			Scopes:    input.Scopes,
+			ExpiresAt: input.ExpiresAt,
+		})
+		if err != nil {
+			http.Error(w, "Failed to create API key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(apiKeyCreatedResponse{APIKey: *key, Key: secret})
+	}
+}
+
+func UpdateAPIKey(db DB, userID, keyID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Name   *string  `json:"name"`
+			Scopes []string `json:"scopes"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		key, err := repositories.GetAPIKey(r.Context(), db.(*sql.DB), userID, keyID)
+		if err != nil {
+			http.Error(w, "Failed to fetch API key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if key == nil || key.RevokedAt != nil {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+
+		name, scopes := key.Name, key.Scopes
+		if input.Name != nil {
+			if *input.Name == "" {
+				http.Error(w, "Name required", http.StatusBadRequest)
+				return
+			}
+			name = *input.Name
+		}
+
+		if input.Scopes != nil {
+			owner, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), userID)
+			if err != nil || owner == nil {
+				http.Error(w, "failed to verify user", http.StatusInternalServerError)
+				return
+			}
+
+			slices.Sort(input.Scopes)
+			scopes = slices.Compact(input.Scopes)
+			if err := validateAPIKeyScopes(scopes, owner); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, scope := range scopes {
+				if !requireScope(w, r, scope) {
+					return
+				}
+			}
+		}
+
+		key, err = repositories.UpdateAPIKey(r.Context(), db.(*sql.DB), userID, keyID, name, scopes)
+		if err != nil {
+			if errors.Is(err, repositories.ErrAPIKeyNotFound) {
+				http.Error(w, "API key not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to update API key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(key)
+	}
+}
+
+func RevokeAPIKey(db DB, userID, keyID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := repositories.RevokeAPIKey(r.Context(), db.(*sql.DB), userID, keyID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrAPIKeyNotFound) {
+				http.Error(w, "API key not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to revoke API key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
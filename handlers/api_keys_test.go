@@ -0,0 +1,436 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/repositories"
+)
+
+var apiKeyColumns = []string{"id", "user_id", "name", "prefix", "key_hash", "scopes", "expires_at", "last_used_at", "revoked_at", "created_at"}
+
+// activeAPIKeyColumns are returned by the key lookup of AuthMiddleware, which
+// adds the owner's tenant.
+var activeAPIKeyColumns = append(slices.Clone(apiKeyColumns), "tenant_id")
+
+// useAPIKeys enables API key authentication against db for the test.
+func useAPIKeys(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	orig := apiKeyDB
+	t.Cleanup(func() { apiKeyDB = orig })
+	EnableAPIKeys(db)
+}
+
+// withAPIKey attaches claims as AuthMiddleware would for an API key of
+// subject limited to scope.
+func withAPIKey(req *http.Request, subject, role, scope string) *http.Request {
+	claims := &Claims{Role: role, Scope: scope, APIKeyID: "k1", RegisteredClaims: jwt.RegisteredClaims{Subject: subject}}
+	return req.WithContext(context.WithValue(req.Context(), claimsContextKey, claims))
+}
+
+// expectAPIKeyLookup expects AuthMiddleware to resolve key to key k1 of u1
+// with scope, owned by a user with role.
+func expectAPIKeyLookup(mock sqlmock.Sqlmock, key, scope, role string) {
+	mock.ExpectQuery("SELECT id, user_id, name, prefix").
+		WithArgs(hashOpaqueToken(key)).
+		WillReturnRows(sqlmock.NewRows(activeAPIKeyColumns).
+			AddRow("k1", "u1", "etl", "pgc_abcd1234", hashOpaqueToken(key), scope, nil, nil, nil, time.Now(), "default"))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", role)...))
+	mock.ExpectExec("UPDATE api_keys").
+		WithArgs("k1", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	useAPIKeys(t, db)
+
+	key := "pgc_abcd1234_secret"
+	headers := map[string]func(*http.Request){
+		"X-API-Key":     func(r *http.Request) { r.Header.Set("X-API-Key", key) },
+		"Authorization": func(r *http.Request) { r.Header.Set("Authorization", "ApiKey "+key) },
+	}
+
+	for name, setHeader := range headers {
+		t.Run(name, func(t *testing.T) {
+			expectAPIKeyLookup(mock, key, "assets:write", "admin")
+
+			req := httptest.NewRequest(http.MethodPost, "/assets", nil)
+			setHeader(req)
+			rec := httptest.NewRecorder()
+
+			var got *Claims
+			AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = claimsFromContext(r.Context())
+			}).ServeHTTP(rec, req)
+
+			if got == nil || got.Subject != "u1" || got.Role != "admin" || got.Scope != "assets:write" || got.APIKeyID != "k1" {
+				t.Fatalf("unexpected claims: %+v (status %d: %s)", got, rec.Code, rec.Body.String())
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_InvalidAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	useAPIKeys(t, db)
+
+	mock.ExpectQuery("SELECT id, user_id, name, prefix").
+		WithArgs(hashOpaqueToken("pgc_revoked")).
+		WillReturnRows(sqlmock.NewRows(activeAPIKeyColumns))
+
+	req := httptest.NewRequest(http.MethodGet, "/assets", nil)
+	req.Header.Set("X-API-Key", "pgc_revoked")
+	rec := httptest.NewRecorder()
+
+	AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("invalid API key reached the handler")
+	}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAPIKeyScopes_AreEnforced(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	cases := map[string]struct {
+		method  string
+		path    string
+		scope   string
+		handler func(DB) http.HandlerFunc
+	}{
+		"add favourite with read scope":     {http.MethodPost, "/users/u1/favourites", "favourites:read", FavouritesRouter},
+		"read favourites with write scope":  {http.MethodGet, "/users/u1/favourites", "favourites:write", FavouritesRouter},
+		"create asset without assets:write": {http.MethodPost, "/assets", "favourites:read favourites:write", AssetsRouter},
+		"list users without users:admin":    {http.MethodGet, "/users", "assets:write", UserRouter},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := withAPIKey(httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{}`)), "u1", "admin", tc.scope)
+			rec := httptest.NewRecorder()
+
+			tc.handler(db).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func TestCreateAPIKey_ReturnsKeyOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "admin")...))
+
+	mock.ExpectQuery("INSERT INTO api_keys").
+		WithArgs(sqlmock.AnyArg(), "u1", "etl", sqlmock.AnyArg(), sqlmock.AnyArg(), "assets:write", nil, "default").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+			AddRow("k1", "u1", "etl", "pgc_abcd1234", "stored", "assets:write", nil, nil, nil, time.Now()))
+
+	body := `{"name":"etl","scopes":["assets:write","assets:write"]}`
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/users/u1/api-keys", strings.NewReader(body)), "u1")
+	rec := httptest.NewRecorder()
+
+	APIKeysRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	key, _ := resp["key"].(string)
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		t.Fatalf("key = %q, want %s prefix", key, apiKeyPrefix)
+	}
+	if _, ok := resp["key_hash"]; ok {
+		t.Fatalf("key hash must not be returned: %v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateAPIKey_AdminScopeRequiresAdmin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", "member")...))
+
+	body := `{"name":"etl","scopes":["users:admin"]}`
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/users/u2/api-keys", strings.NewReader(body)), "u2")
+	rec := httptest.NewRecorder()
+
+	APIKeysRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAPIKeysRouter_RejectsAPIKeyCallers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	req := withAPIKey(httptest.NewRequest(http.MethodGet, "/users/u1/api-keys", nil), "u1", "member", "favourites:read")
+	rec := httptest.NewRecorder()
+
+	APIKeysRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevokeAPIKey_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE api_keys").
+		WithArgs("u1", "k9", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := withSubject(httptest.NewRequest(http.MethodDelete, "/users/u1/api-keys/k9", nil), "u1")
+	rec := httptest.NewRecorder()
+
+	APIKeysRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAuthMiddleware_APIKeyOfDisabledUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	useAPIKeys(t, db)
+
+	key := "pgc_abcd1234_secret"
+	mock.ExpectQuery("SELECT id, user_id, name, prefix").
+		WithArgs(hashOpaqueToken(key)).
+		WillReturnRows(sqlmock.NewRows(activeAPIKeyColumns).
+			AddRow("k1", "u1", "etl", "pgc_abcd1234", hashOpaqueToken(key), "assets:read", nil, nil, nil, time.Now(), "default"))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(disabledUserRow("u1", "Alice", "hash", "member")...))
+
+	req := httptest.NewRequest(http.MethodGet, "/assets", nil)
+	req.Header.Set("X-API-Key", key)
+	rec := httptest.NewRecorder()
+
+	AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("API key of a disabled user reached the handler")
+	}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateAPIKey_CannotExceedCallerScope(t *testing.T) {
+	cases := map[string]struct {
+		role, scope, requested string
+	}{
+		"read token creating write key":    {"member", "favourites:read", "favourites:write"},
+		"limited admin creating admin key": {"admin", "favourites:read", "users:admin"},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New error: %v", err)
+			}
+			defer db.Close()
+
+			mock.ExpectQuery("SELECT id, name, password_hash").
+				WithArgs("u1", "default").
+				WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(userRow("u1", "Alice", "hash", tt.role)...))
+
+			body := `{"name":"etl","scopes":["` + tt.requested + `"]}`
+			req := withScope(httptest.NewRequest(http.MethodPost, "/users/u1/api-keys", strings.NewReader(body)), "u1", tt.role, tt.scope)
+			rec := httptest.NewRecorder()
+
+			APIKeysRouter(db).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateAPIKey_CannotExceedCallerScope(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, user_id, name, prefix").
+		WithArgs("u1", "k1", "default").
+		WillReturnRows(sqlmock.NewRows(apiKeyColumns).
+			AddRow("k1", "u1", "etl", "pgc_abcd1234", "stored", "favourites:read", nil, nil, nil, time.Now()))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
+
+	body := `{"scopes":["favourites:read","favourites:write"]}`
+	req := withScope(httptest.NewRequest(http.MethodPatch, "/users/u1/api-keys/k1", strings.NewReader(body)), "u1", "member", "favourites:read")
+	rec := httptest.NewRecorder()
+
+	APIKeysRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAuthMiddleware_APIKeyTenant(t *testing.T) {
+	origHosts := tenantHosts
+	defer func() { tenantHosts = origHosts }()
+	tenantHosts = parseTenantHosts("globex.example.com=globex")
+
+	cases := map[string]struct {
+		host       string
+		wantStatus int
+	}{
+		"owner's tenant applies on shared hosts": {"api.example.com", http.StatusOK},
+		"refused on another tenant's host":       {"globex.example.com", http.StatusUnauthorized},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New error: %v", err)
+			}
+			defer db.Close()
+
+			useAPIKeys(t, db)
+
+			key := "pgc_abcd1234_secret"
+			mock.ExpectQuery("SELECT id, user_id, name, prefix").
+				WithArgs(hashOpaqueToken(key)).
+				WillReturnRows(sqlmock.NewRows(activeAPIKeyColumns).
+					AddRow("k1", "u1", "etl", "pgc_abcd1234", hashOpaqueToken(key), "favourites:read", nil, nil, nil, time.Now(), "acme"))
+			mock.ExpectQuery("SELECT id, name, password_hash").
+				WithArgs("u1", "acme").
+				WillReturnRows(sqlmock.NewRows(userColumns).
+					AddRow(userRow("u1", "Alice", "hash", "member")...))
+			mock.ExpectExec("UPDATE api_keys").
+				WithArgs("k1", sqlmock.AnyArg(), "acme").
+				WillReturnResult(sqlmock.NewResult(0, 1))
+
+			req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/assets", nil)
+			req.Header.Set("X-API-Key", key)
+			rec := httptest.NewRecorder()
+
+			var tenant string
+			AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				tenant = repositories.TenantFromContext(r.Context())
+			}).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && tenant != "acme" {
+				t.Fatalf("tenant = %q, want acme", tenant)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
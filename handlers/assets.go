@@ -25,15 +25,18 @@ func AssetsRouter(db DB) http.HandlerFunc {
 
 		switch r.Method {
 		case http.MethodPost:
-			// POST /assets - Create a new asset
+			// POST /assets - Create a new asset, for admins only
 			if len(parts) == 1 {
+				if !requireScope(w, r, models.ScopeAssetsWrite) {
+					return
+				}
 				println("Create a new asset")
-				CreateAsset(db)(w, r)
+				RequireRole(CreateAsset(db), models.RoleAdmin)(w, r)
 				return
 			}
 
 		case http.MethodGet:
-			// GET /assets - List all assets
+			// GET /assets - List all assets, or those of an organization
 			if len(parts) == 1 {
 				println("List all assets")
 				GetAllAssets(db)(w, r)
@@ -51,6 +54,8 @@ func AssetsRouter(db DB) http.HandlerFunc {
 	}
 }
 
+// CreateAsset creates an asset owned by the caller. Assets of an organization
+// are visible to its members by default, other assets to everyone.
 func CreateAsset(db DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -59,10 +64,12 @@ func CreateAsset(db DB) http.HandlerFunc {
 		}
 
 		var input struct {
-			Type        models.AssetType `json:"type"`
-			Title       string           `json:"title"`
-			Description *string          `json:"description"`
-			Data        json.RawMessage  `json:"data"`
+			Type        models.AssetType       `json:"type"`
+			Title       string                 `json:"title"`
+			Description *string                `json:"description"`
+			Data        json.RawMessage        `json:"data"`
+			OrgID       *string                `json:"org_id"`
+			Visibility  models.AssetVisibility `json:"visibility"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -81,10 +88,42 @@ func CreateAsset(db DB) http.HandlerFunc {
 			return
 		}
 
+		if input.Visibility == "" {
+			input.Visibility = models.VisibilityPublic
+			if input.OrgID != nil {
+				input.Visibility = models.VisibilityOrg
+			}
+		}
+		if !models.ValidAssetVisibility(input.Visibility) {
+			http.Error(w, "Invalid visibility (private, org, or public)", http.StatusBadRequest)
+			return
+		}
+		if input.Visibility == models.VisibilityOrg && input.OrgID == nil {
+			http.Error(w, "org_id is required for org visibility", http.StatusBadRequest)
+			return
+		}
+
+		if input.OrgID != nil {
+			org, err := repositories.GetOrganization(r.Context(), db.(*sql.DB), *input.OrgID)
+			if err != nil {
+				http.Error(w, "Failed to fetch organization: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if org == nil {
+				http.Error(w, "Unknown organization", http.StatusBadRequest)
+				return
+			}
+		}
+
 		asset := models.Asset{
-			Type:  input.Type,
-			Title: &input.Title,
-			Data:  input.Data,
+			Type:       input.Type,
+			Title:      &input.Title,
+			Data:       input.Data,
+			OrgID:      input.OrgID,
+			Visibility: input.Visibility,
+		}
+		if claims, ok := claimsFromContext(r.Context()); ok && claims.Subject != "" {
+			asset.CreatedBy = &claims.Subject
 		}
 
 		assetID, err := repositories.CreateAsset(r.Context(), db.(*sql.DB), asset, input.Description)
@@ -103,10 +142,15 @@ func CreateAsset(db DB) http.HandlerFunc {
 			"title":       asset.Title,
 			"description": input.Description,
 			"data":        asset.Data,
+			"org_id":      asset.OrgID,
+			"created_by":  asset.CreatedBy,
+			"visibility":  asset.Visibility,
 		})
 	}
 }
 
+// GetAsset returns an asset visible to the caller. Assets they may not see
+// are reported as not found.
 func GetAsset(db DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
@@ -129,9 +173,17 @@ func GetAsset(db DB) http.HandlerFunc {
 	}
 }
 
+// GetAllAssets lists every asset visible to the caller. Within an
+// organization, given by ?org= or the active organization of the token, only
+// the organization's assets are listed.
 func GetAllAssets(db DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		assets, err := repositories.ListAssets(r.Context(), db.(*sql.DB))
+		orgID, ok := orgScope(w, r, db.(*sql.DB))
+		if !ok {
+			return
+		}
+
+		assets, err := repositories.ListAssets(r.Context(), db.(*sql.DB), orgID)
 		if err != nil {
 			http.Error(w, "Failed to fetch assets: "+err.Error(), http.StatusInternalServerError)
 			return
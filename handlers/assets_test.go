@@ -88,9 +88,11 @@ func TestCreateAsset_Success(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/assets", strings.NewReader(body))
 	rec := httptest.NewRecorder()
 
+	expectUserTx(mock, "", "")
 	mock.ExpectQuery("INSERT INTO assets").
-		WithArgs(models.AssetChart, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(models.AssetChart, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "default", nil, models.VisibilityPublic).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1"))
+	mock.ExpectCommit()
 
 	handler := CreateAsset(db)
 	handler.ServeHTTP(rec, req)
@@ -119,9 +121,11 @@ func TestGetAsset_NotFound(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").
-		WithArgs("missing").
+	expectUserTx(mock, "", "")
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("missing", "default", "", false).
 		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
 	req := httptest.NewRequest(http.MethodGet, "/assets/missing", nil)
 	rec := httptest.NewRecorder()
@@ -142,10 +146,12 @@ func TestGetAsset_Success(t *testing.T) {
 	defer db.Close()
 
 	createdAt := time.Now()
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").
-		WithArgs("a1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "title", "data", "created_at"}).
-			AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{"x":1}`), createdAt))
+	expectUserTx(mock, "", "")
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("a1", "default", "", false).
+		WillReturnRows(sqlmock.NewRows(assetColumns).
+			AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{"x":1}`), createdAt, nil, nil, models.VisibilityPublic))
+	mock.ExpectCommit()
 
 	req := httptest.NewRequest(http.MethodGet, "/assets/a1", nil)
 	rec := httptest.NewRecorder()
@@ -177,11 +183,13 @@ func TestGetAllAssets_Success(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "type", "title", "data", "created_at"}).
-		AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{"x":1}`), time.Now()).
-		AddRow("a2", models.AssetInsight, "Insight", json.RawMessage(`{"text":"hi"}`), time.Now())
+	rows := sqlmock.NewRows(assetColumns).
+		AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{"x":1}`), time.Now(), nil, nil, models.VisibilityPublic).
+		AddRow("a2", models.AssetInsight, "Insight", json.RawMessage(`{"text":"hi"}`), time.Now(), nil, nil, models.VisibilityPublic)
 
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").WillReturnRows(rows)
+	expectUserTx(mock, "", "")
+	mock.ExpectQuery("FROM assets a").WillReturnRows(rows)
+	mock.ExpectCommit()
 
 	req := httptest.NewRequest(http.MethodGet, "/assets", nil)
 	rec := httptest.NewRecorder()
@@ -205,3 +213,158 @@ func TestGetAllAssets_Success(t *testing.T) {
 		t.Fatalf("unmet expectations: %v", err)
 	}
 }
+
+func TestCreateAsset_InvalidVisibility(t *testing.T) {
+	body := `{"type":"chart","title":"Sales","data":{},"visibility":"secret"}`
+	req := httptest.NewRequest(http.MethodPost, "/assets", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	var mockDB *sql.DB
+	CreateAsset(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rec.Body.String(), "Invalid visibility") {
+		t.Fatalf("expected invalid visibility message, got %q", rec.Body.String())
+	}
+}
+
+func TestCreateAsset_OrgVisibilityRequiresOrganization(t *testing.T) {
+	body := `{"type":"chart","title":"Sales","data":{},"visibility":"org"}`
+	req := httptest.NewRequest(http.MethodPost, "/assets", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	var mockDB *sql.DB
+	CreateAsset(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rec.Body.String(), "org_id is required") {
+		t.Fatalf("expected org_id required message, got %q", rec.Body.String())
+	}
+}
+
+func TestCreateAsset_RecordsCreator(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectQuery("INSERT INTO assets").
+		WithArgs(models.AssetChart, sqlmock.AnyArg(), nil, sqlmock.AnyArg(), nil, "default", "u1", models.VisibilityPrivate).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1"))
+	mock.ExpectCommit()
+
+	body := `{"type":"chart","title":"Sales","data":{},"visibility":"private"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/assets", strings.NewReader(body)), "u1", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	CreateAsset(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["created_by"] != "u1" || resp["visibility"] != "private" {
+		t.Fatalf("unexpected asset response: %v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAssetsRouter_OrgMemberCannotCreateOrgAsset(t *testing.T) {
+	// No DB expectations: members are refused before the organization is
+	// looked up, whatever their membership
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	body := `{"type":"chart","title":"Sales","data":{},"org_id":"acme","visibility":"org"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/assets", strings.NewReader(body)), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	AssetsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAssetsRouter_AdminCreatesOrgAsset(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("FROM organizations").
+		WithArgs("acme", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
+			AddRow("acme", "Acme", time.Now(), time.Now()))
+	expectUserTx(mock, "admin1", models.RoleAdmin)
+	mock.ExpectQuery("INSERT INTO assets").
+		WithArgs(models.AssetChart, sqlmock.AnyArg(), nil, sqlmock.AnyArg(), "acme", "default", "admin1", models.VisibilityOrg).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1"))
+	mock.ExpectCommit()
+
+	body := `{"type":"chart","title":"Sales","data":{},"org_id":"acme"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/assets", strings.NewReader(body)), "admin1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	AssetsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetAsset_PrivateAssetOfOtherUserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	// The asset exists but belongs to another user, so the query finds nothing
+	expectUserTx(mock, "u2", models.RoleMember)
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("a1", "default", "u2", false).
+		WillReturnRows(sqlmock.NewRows(assetColumns))
+	mock.ExpectRollback()
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/assets/a1", nil), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	GetAsset(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// assetColumns are the columns of an assets row.
+var assetColumns = []string{"id", "type", "title", "data", "created_at", "org_id", "created_by", "visibility"}
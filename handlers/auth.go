@@ -1,36 +1,254 @@
 package handlers
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
 )
 
 var jwtSecret []byte
 var authEnabled bool
 
+var accessTokenTTL = 15 * time.Minute
+var refreshTokenTTL = 30 * 24 * time.Hour
+
+// Claims are the JWT claims issued by Login.
+type Claims struct {
+	Role string `json:"role"`
+	// TokenUse is empty for access tokens and set for special purpose tokens,
+	// such as two-factor challenges, which AuthMiddleware refuses.
+	TokenUse string `json:"token_use,omitempty"`
+	// Scope is a space-separated list of scopes the credential is limited
+	// to. Empty means unrestricted.
+	Scope string `json:"scope,omitempty"`
+	// Email is the address confirmed by an email verification token.
+	Email string `json:"email,omitempty"`
+	// Restore is set on two-factor challenges of logins restoring a
+	// deleted account.
+	Restore bool `json:"restore,omitempty"`
+	// TenantID is the tenant of the subject. Empty means the default tenant.
+	TenantID string `json:"tenant,omitempty"`
+	// OrgID is the organization the user is acting in, with their OrgRole
+	// in it. Both are empty until the user switches to an organization.
+	OrgID   string `json:"org,omitempty"`
+	OrgRole string `json:"org_role,omitempty"`
+	// Act is set on impersonation tokens and names the admin acting as
+	// the subject, as in RFC 8693.
+	Act *Actor `json:"act,omitempty"`
+	// APIKeyID is set when the request was authenticated with an API key
+	// rather than a token.
+	APIKeyID string `json:"-"`
+	jwt.RegisteredClaims
+}
+
+// Actor is the party actually making requests with an impersonation token.
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+type contextKey string
+
+const claimsContextKey contextKey = "claims"
+
 func init() {
+	// Asymmetric keys take precedence over the shared HMAC secret
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		keys, active, err := loadSigningKeys(dir, os.Getenv("JWT_ACTIVE_KID"))
+		if err != nil {
+			log.Fatalf("failed to load JWT signing keys: %v", err)
+		}
+		signingKeys, activeKey = keys, active
+	}
+
 	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-secret-key-change-in-production" // Fallback for dev
+	if secret == "" && activeKey == nil {
+		// Random per-process secret for dev: tokens do not survive restarts
+		log.Println("⚠️  JWT_SECRET and JWT_KEYS_DIR are not set, using an ephemeral signing secret")
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			log.Fatalf("failed to generate JWT secret: %v", err)
+		}
+		secret = string(b)
 	}
 	jwtSecret = []byte(secret)
 
 	// Auth is enabled by default; disable by setting AUTH_DISABLED=true
 	authEnabled = os.Getenv("AUTH_DISABLED") != "true"
+
+	// Token lifetimes, e.g. ACCESS_TOKEN_TTL=10m REFRESH_TOKEN_TTL=168h
+	if ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && ttl > 0 {
+		accessTokenTTL = ttl
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && ttl > 0 {
+		refreshTokenTTL = ttl
+	}
 }
 
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+// IssueToken signs an access token for user carrying its role, scopes and a
+// unique jti, using the active asymmetric key when one is configured. Without
+// scopes the token gets every scope allowed for the user's role.
+func IssueToken(user *models.User, scopes ...string) (string, error) {
+	if len(scopes) == 0 {
+		scopes = models.ScopesForRole(user.Role)
+	}
+
+	return signAccessToken(accessClaims(user, strings.Join(scopes, " ")), accessTokenTTL)
+}
+
+// accessClaims returns the claims of an access token for user limited to
+// scope.
+func accessClaims(user *models.User, scope string) Claims {
+	return Claims{
+		Role:             user.Role,
+		Scope:            scope,
+		TenantID:         user.TenantID,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: user.ID},
+	}
+}
+
+// signAccessToken signs claims as an access token valid for ttl, after
+// giving them a unique jti and the issue and expiry times.
+func signAccessToken(claims Claims, ttl time.Duration) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.ID = hex.EncodeToString(jti)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	claims.IssuedAt = jwt.NewNumericDate(now)
+
+	return signToken(claims)
+}
+
+// claimsFromContext returns the token claims stored by AuthMiddleware.
+func claimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok && claims != nil
+}
+
+// canAccessUser reports whether the caller may act on the resources of userID.
+// Callers may always access their own resources; admins may access anyone's.
+func canAccessUser(r *http.Request, userID string) bool {
+	if !authEnabled {
+		return true
+	}
+
+	claims, ok := claimsFromContext(r.Context())
+	if !ok || claims.Subject == "" {
+		return false
+	}
+
+	return claims.Subject == userID || claims.Role == models.RoleAdmin
+}
+
+// RequireRole only lets callers whose token carries one of roles through to
+// next. It must be wrapped by AuthMiddleware so the claims are available.
+func RequireRole(next http.HandlerFunc, roles ...string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if !authEnabled {
 			next(w, r)
 			return
 		}
 
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || !slices.Contains(roles, claims.Role) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// contextWithClaims stores claims for the handlers and makes their subject
+// the current user of the row-level security policies of the database.
+func contextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	ctx = repositories.WithCurrentUser(ctx, claims.Subject, claims.Role)
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authEnabled {
+			// Without auth every caller is trusted, as an admin would be
+			ctx := repositories.WithCurrentUser(r.Context(), "", models.RoleAdmin)
+			next(w, r.WithContext(ctx))
+			return
+		}
+
+		if key := apiKeyFromRequest(r); key != "" {
+			if apiKeyDB == nil {
+				http.Error(w, "API keys are not enabled", http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := authenticateAPIKey(r.Context(), key)
+			if err != nil {
+				http.Error(w, "Failed to verify API key: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if claims == nil {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+
+			ctx, err := authorizeTenant(r, claims)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			ctx = contextWithClaims(ctx, claims)
+			next(w, r.WithContext(ctx))
+			return
+		}
+
 		auth := r.Header.Get("Authorization")
 		if auth == "" {
+			// Browser sessions send the access token as a cookie instead,
+			// which requires a CSRF token for anything that changes state
+			if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+				claims, err := parseAccessToken(cookie.Value)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+
+				if !safeMethod(r.Method) && !validCSRFToken(r) {
+					http.Error(w, errCSRF.Error(), http.StatusForbidden)
+					return
+				}
+
+				ctx, err := authorizeTenant(r, claims)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusUnauthorized)
+					return
+				}
+
+				h := next
+				if claims.Act != nil {
+					h = auditImpersonation(next)
+				}
+
+				ctx = contextWithClaims(ctx, claims)
+				h(w, r.WithContext(ctx))
+				return
+			}
+
 			http.Error(w, "Missing authorization header", http.StatusUnauthorized)
 			return
 		}
@@ -41,23 +259,49 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(parts[1], &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, http.ErrAbortHandler
-			}
-			return jwtSecret, nil
-		})
-
+		claims, err := parseAccessToken(parts[1])
 		if err != nil {
-			http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		if !token.Valid {
-			http.Error(w, "Token invalid or expired", http.StatusUnauthorized)
+		ctx, err := authorizeTenant(r, claims)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		next(w, r)
+		h := next
+		if claims.Act != nil {
+			h = auditImpersonation(next)
+		}
+
+		ctx = contextWithClaims(ctx, claims)
+		h(w, r.WithContext(ctx))
 	}
 }
+
+// parseAccessToken verifies an access token and returns its claims. The
+// error is suitable for a 401 response.
+func parseAccessToken(raw string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(raw, claims, verificationKey)
+
+	if err != nil {
+		return nil, errors.New("Invalid token: " + err.Error())
+	}
+
+	if !token.Valid {
+		return nil, errors.New("Token invalid or expired")
+	}
+
+	if claims.TokenUse != "" {
+		return nil, errors.New("Invalid token: not an access token")
+	}
+
+	if denylist != nil && denylist.isRevoked(claims) {
+		return nil, errors.New("Token has been revoked")
+	}
+
+	return claims, nil
+}
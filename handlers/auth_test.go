@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/models"
 )
 
 func TestAuthMiddleware_DisabledAuth(t *testing.T) {
@@ -277,6 +279,113 @@ func TestAuthMiddleware_WrongSecret(t *testing.T) {
 	}
 }
 
+func TestAuthMiddleware_StoresClaimsInContext(t *testing.T) {
+	// Save original state
+	origAuthEnabled := authEnabled
+	origSecret := jwtSecret
+	defer func() {
+		authEnabled = origAuthEnabled
+		jwtSecret = origSecret
+	}()
+
+	testSecret := []byte("test-secret")
+	authEnabled = true
+	jwtSecret = testSecret
+
+	var subject string
+	next := func(w http.ResponseWriter, r *http.Request) {
+		if claims, ok := claimsFromContext(r.Context()); ok {
+			subject = claims.Subject
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "user123",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+
+	tokenString, err := token.SignedString(testSecret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	rec := httptest.NewRecorder()
+
+	AuthMiddleware(next).ServeHTTP(rec, req)
+
+	if subject != "user123" {
+		t.Fatalf("subject = %q, want %q", subject, "user123")
+	}
+}
+
+func TestIssueToken_CarriesRole(t *testing.T) {
+	origSecret := jwtSecret
+	defer func() { jwtSecret = origSecret }()
+	jwtSecret = []byte("test-secret")
+
+	tokenString, err := IssueToken(&models.User{ID: "u1", Role: models.RoleAdmin})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	claims := &Claims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}); err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+
+	if claims.Subject != "u1" || claims.Role != models.RoleAdmin {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	origAuthEnabled := authEnabled
+	defer func() { authEnabled = origAuthEnabled }()
+	authEnabled = true
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{
+			name:       "admin allowed",
+			req:        withClaims(httptest.NewRequest(http.MethodGet, "/test", nil), "u1", models.RoleAdmin),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "member forbidden",
+			req:        withClaims(httptest.NewRequest(http.MethodGet, "/test", nil), "u2", models.RoleMember),
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "missing claims forbidden",
+			req:        httptest.NewRequest(http.MethodGet, "/test", nil),
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			RequireRole(next, models.RoleAdmin).ServeHTTP(rec, tt.req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
 // Helper to check if substring is in a string
 func containsString(haystack, needle string) bool {
 	return strings.Contains(haystack, needle)
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/repositories"
+)
+
+// tokenDenylist is an in-memory copy of the revocations stored in Postgres so
+// AuthMiddleware does not need a query per request. Revocations made by this
+// process apply immediately; ones made by other instances are picked up on
+// the next refresh.
+type tokenDenylist struct {
+	mu    sync.RWMutex
+	jtis  map[string]time.Time // jti -> token expiry
+	users map[string]time.Time // user ID -> tokens issued until then are revoked
+}
+
+func init() {
+	// Token iat carries milliseconds, so that revoking a user's tokens can
+	// tell apart the ones issued earlier and later in the same second
+	jwt.TimePrecision = time.Millisecond
+}
+
+// denylist is nil until EnableTokenDenylist is called, in which case
+// revocations are stored but not enforced by AuthMiddleware.
+var denylist *tokenDenylist
+
+func newTokenDenylist() *tokenDenylist {
+	return &tokenDenylist{
+		jtis:  make(map[string]time.Time),
+		users: make(map[string]time.Time),
+	}
+}
+
+// EnableTokenDenylist loads revoked tokens from db and keeps them in sync
+// every interval until ctx is cancelled, purging expired entries on the way.
+func EnableTokenDenylist(ctx context.Context, db *sql.DB, interval time.Duration) error {
+	d := newTokenDenylist()
+	if err := d.refresh(ctx, db); err != nil {
+		return err
+	}
+	denylist = d
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				// Anything older than the access token TTL can no longer be used
+				if _, err := repositories.PurgeExpiredRevocations(ctx, db, time.Now().Add(-accessTokenTTL)); err != nil {
+					log.Printf("failed to purge expired revocations: %v", err)
+				}
+				if err := d.refresh(ctx, db); err != nil {
+					log.Printf("failed to refresh token denylist: %v", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// refresh replaces the cached revocations with the ones stored in db.
+func (d *tokenDenylist) refresh(ctx context.Context, db *sql.DB) error {
+	tokens, err := repositories.ListRevokedTokens(ctx, db)
+	if err != nil {
+		return err
+	}
+	users, err := repositories.ListUserTokenRevocations(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	jtis := make(map[string]time.Time, len(tokens))
+	for _, t := range tokens {
+		jtis[t.JTI] = t.ExpiresAt
+	}
+	revokedUsers := make(map[string]time.Time, len(users))
+	for _, u := range users {
+		revokedUsers[u.UserID] = u.RevokedBefore
+	}
+
+	d.mu.Lock()
+	d.jtis, d.users = jtis, revokedUsers
+	d.mu.Unlock()
+
+	return nil
+}
+
+func (d *tokenDenylist) revokeToken(jti string, expiresAt time.Time) {
+	d.mu.Lock()
+	d.jtis[jti] = expiresAt
+	d.mu.Unlock()
+}
+
+func (d *tokenDenylist) revokeUser(userID string, before time.Time) {
+	d.mu.Lock()
+	if before.After(d.users[userID]) {
+		d.users[userID] = before
+	}
+	d.mu.Unlock()
+}
+
+// isRevoked reports whether claims belong to a revoked token.
+func (d *tokenDenylist) isRevoked(claims *Claims) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if claims.ID != "" {
+		if _, ok := d.jtis[claims.ID]; ok {
+			return true
+		}
+	}
+
+	if before, ok := d.users[claims.Subject]; ok {
+		return claims.IssuedAt == nil || !claims.IssuedAt.After(before)
+	}
+
+	return false
+}
+
+// revokeAllUserTokens revokes every access and refresh token issued to userID
+// so far, e.g. after a password change.
+func revokeAllUserTokens(ctx context.Context, db *sql.DB, userID string) error {
+	// Tokens issued up to and including this millisecond are revoked
+	before := time.Now().Truncate(jwt.TimePrecision)
+
+	if err := repositories.RevokeUserTokens(ctx, db, userID, before); err != nil {
+		return err
+	}
+	if err := repositories.RevokeUserRefreshTokens(ctx, db, userID); err != nil {
+		return err
+	}
+
+	if denylist != nil {
+		denylist.revokeUser(userID, before)
+	}
+
+	// Let the revoked millisecond pass, so that tokens issued once this
+	// returns, like the new pair after a password change, stay valid
+	time.Sleep(time.Until(before.Add(jwt.TimePrecision)))
+
+	return nil
+}
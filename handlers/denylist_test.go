@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/models"
+)
+
+// useDenylist installs an empty denylist for the duration of the test.
+func useDenylist(t *testing.T) *tokenDenylist {
+	t.Helper()
+
+	orig := denylist
+	t.Cleanup(func() { denylist = orig })
+
+	denylist = newTokenDenylist()
+	return denylist
+}
+
+func TestTokenDenylist_IsRevoked(t *testing.T) {
+	d := newTokenDenylist()
+	now := time.Now().Truncate(time.Second).Add(500 * time.Millisecond)
+
+	d.revokeToken("stolen", now.Add(time.Hour))
+	d.revokeUser("u2", now)
+
+	tests := []struct {
+		name   string
+		claims Claims
+		want   bool
+	}{
+		{"revoked jti", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "stolen", Subject: "u1", IssuedAt: jwt.NewNumericDate(now)}}, true},
+		{"other jti", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "fine", Subject: "u1", IssuedAt: jwt.NewNumericDate(now)}}, false},
+		{"user token issued before revocation", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "a", Subject: "u2", IssuedAt: jwt.NewNumericDate(now.Add(-time.Minute))}}, true},
+		{"user token issued in the same second", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "d", Subject: "u2", IssuedAt: jwt.NewNumericDate(now.Add(-300 * time.Millisecond))}}, true},
+		{"user token issued at revocation", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "e", Subject: "u2", IssuedAt: jwt.NewNumericDate(now)}}, true},
+		{"user token issued after revocation", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "b", Subject: "u2", IssuedAt: jwt.NewNumericDate(now.Add(time.Second))}}, false},
+		{"user token without iat", Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "c", Subject: "u2"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.isRevoked(&tt.claims); got != tt.want {
+				t.Fatalf("isRevoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenDenylist_Refresh(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT jti").
+		WillReturnRows(sqlmock.NewRows([]string{"jti", "user_id", "expires_at", "revoked_at"}).
+			AddRow("stolen", nil, now.Add(time.Hour), now))
+	mock.ExpectQuery("SELECT user_id, revoked_before").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "revoked_before"}).
+			AddRow("u2", now))
+
+	d := newTokenDenylist()
+	if err := d.refresh(context.Background(), db); err != nil {
+		t.Fatalf("refresh error: %v", err)
+	}
+
+	if !d.isRevoked(&Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "stolen"}}) {
+		t.Fatal("expected jti loaded from db to be revoked")
+	}
+	if !d.isRevoked(&Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u2", IssuedAt: jwt.NewNumericDate(now.Add(-time.Hour))}}) {
+		t.Fatal("expected user revocation loaded from db to apply")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAuthMiddleware_RejectsRevokedToken(t *testing.T) {
+	origAuthEnabled := authEnabled
+	defer func() { authEnabled = origAuthEnabled }()
+	authEnabled = true
+
+	d := useDenylist(t)
+
+	token, err := IssueToken(&models.User{ID: "u1", Role: models.RoleMember})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	if code := authenticate(token); code != http.StatusOK {
+		t.Fatalf("status before revocation = %d, want %d", code, http.StatusOK)
+	}
+
+	claims := &Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if claims.ID == "" {
+		t.Fatal("expected token to carry a jti")
+	}
+	d.revokeToken(claims.ID, claims.ExpiresAt.Time)
+
+	if code := authenticate(token); code != http.StatusUnauthorized {
+		t.Fatalf("status after revocation = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestRevokeAllUserTokens_SparesTokensIssuedAfterwards(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	d := useDenylist(t)
+
+	mock.ExpectExec("INSERT INTO user_token_revocations").
+		WithArgs("u2", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens").
+		WithArgs("u2", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := revokeAllUserTokens(context.Background(), db, "u2"); err != nil {
+		t.Fatalf("revokeAllUserTokens error: %v", err)
+	}
+
+	// Issued right away, as ChangePassword does with the new pair
+	issued := jwt.NewNumericDate(time.Now())
+	if d.isRevoked(&Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u2", IssuedAt: issued}}) {
+		t.Fatal("expected a token issued after the revocation to stay valid")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
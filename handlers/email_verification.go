@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/mail"
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+const tokenUseEmailVerification = "email_verification"
+
+var emailVerificationTTL = 24 * time.Hour
+
+// emailVerificationResendInterval is the minimum time between two
+// verification emails to the same user.
+var emailVerificationResendInterval = time.Minute
+
+// emailVerificationURL is the page users are sent to, with ?token= appended.
+var emailVerificationURL = "http://localhost:8080/email/verify"
+
+func init() {
+	// Email verification, e.g. EMAIL_VERIFICATION_TTL=48h EMAIL_VERIFICATION_RESEND_INTERVAL=5m
+	if verifyURL := os.Getenv("EMAIL_VERIFICATION_URL"); verifyURL != "" {
+		emailVerificationURL = verifyURL
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TTL")); err == nil && ttl > 0 {
+		emailVerificationTTL = ttl
+	}
+	if d, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_RESEND_INTERVAL")); err == nil && d >= 0 {
+		emailVerificationResendInterval = d
+	}
+}
+
+var errVerificationRateLimited = errors.New("Verification email sent recently")
+
+// sendEmailVerification emails user a signed link confirming their current
+// address, unless one was sent less than emailVerificationResendInterval ago.
+func sendEmailVerification(r *http.Request, db *sql.DB, user *models.User) error {
+	ok, err := repositories.MarkEmailVerificationSent(r.Context(), db, user.ID, time.Now().Add(-emailVerificationResendInterval))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errVerificationRateLimited
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return err
+	}
+
+	// The address is part of the token so changing it voids older links
+	now := time.Now()
+	token, err := signToken(Claims{
+		TokenUse: tokenUseEmailVerification,
+		Email:    user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
+			Subject:   user.ID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(emailVerificationTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	link := emailVerificationURL + "?token=" + url.QueryEscape(token)
+	return mailer.Send(r.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to confirm your email address. It expires in %s.\n\n%s\n\nIf you did not create an account, you can ignore this email.\n",
+			user.Name, emailVerificationTTL, link,
+		),
+	})
+}
+
+// VerifyEmail confirms an email address with the token from a verification
+// email: GET /email/verify?token=... or POST /email/verify {"token": "..."}
+func VerifyEmail(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Token string `json:"token"`
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			input.Token = r.URL.Query().Get("token")
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if input.Token == "" {
+			http.Error(w, "Token required", http.StatusBadRequest)
+			return
+		}
+
+		claims := &Claims{}
+		token, err := jwt.ParseWithClaims(input.Token, claims, verificationKey)
+		if err != nil || !token.Valid || claims.TokenUse != tokenUseEmailVerification || claims.Email == "" {
+			http.Error(w, "Invalid or expired verification token", http.StatusBadRequest)
+			return
+		}
+
+		err = repositories.SetEmailVerified(r.Context(), db.(*sql.DB), claims.Subject, claims.Email)
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			http.Error(w, "Invalid or expired verification token", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to verify email: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ResendEmailVerification sends a new verification email to the caller:
+// POST /me/email/verification
+func ResendEmailVerification(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		if !user.EmailUnverified() {
+			http.Error(w, "No unverified email address", http.StatusConflict)
+			return
+		}
+
+		err = sendEmailVerification(r, db.(*sql.DB), user)
+		if errors.Is(err, errVerificationRateLimited) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(emailVerificationResendInterval.Seconds())))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to send verification email: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
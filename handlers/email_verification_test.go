@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/models"
+)
+
+// unverifiedUserRow returns a users row for a user who has not confirmed
+// email yet.
+func unverifiedUserRow(id, name, email string) []driver.Value {
+	row := userRow(id, name, "hash", models.RoleMember)
+	row[5] = email
+	return row
+}
+
+func TestRegister_SendsVerificationEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	outbox := useRecordingMailer(t)
+
+	mock.ExpectQuery("WHERE lower\\(email\\)").
+		WithArgs("carol@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("u3", "Carol", sqlmock.AnyArg(), models.RoleMember, "carol@example.com", "", "", "", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("u3"))
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u3", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"id":"u3","name":"Carol","password":"carol123","email":" carol@example.com "}`
+	rec := httptest.NewRecorder()
+	Register(db).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	if len(outbox.messages) != 1 || outbox.messages[0].To != "carol@example.com" {
+		t.Fatalf("expected one verification email to carol, got %+v", outbox.messages)
+	}
+	_, link, _ := strings.Cut(outbox.messages[0].Body, emailVerificationURL+"?token=")
+	token, err := url.QueryUnescape(strings.Fields(link)[0])
+	if err != nil {
+		t.Fatalf("unescape token: %v", err)
+	}
+
+	claims := &Claims{}
+	if _, err := jwt.ParseWithClaims(token, claims, verificationKey); err != nil {
+		t.Fatalf("verification token does not verify: %v", err)
+	}
+	if claims.TokenUse != tokenUseEmailVerification || claims.Subject != "u3" || claims.Email != "carol@example.com" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestVerifyEmail_MarksAddressVerified(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	token, err := signToken(Claims{
+		TokenUse: tokenUseEmailVerification,
+		Email:    "carol@example.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "u3",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("signToken error: %v", err)
+	}
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u3", "carol@example.com", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	VerifyEmail(db).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/email/verify?token="+url.QueryEscape(token), nil))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestVerifyEmail_RejectsAccessToken(t *testing.T) {
+	token, err := IssueToken(&models.User{ID: "u3", Role: models.RoleMember})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	body := `{"token":"` + token + `"}`
+	rec := httptest.NewRecorder()
+	VerifyEmail(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/email/verify", strings.NewReader(body)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestResendEmailVerification_RateLimited(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	outbox := useRecordingMailer(t)
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u3", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(unverifiedUserRow("u3", "Carol", "carol@example.com")...))
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u3", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/me/email/verification", nil), "u3")
+	rec := httptest.NewRecorder()
+
+	ResendEmailVerification(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusTooManyRequests, rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("expected a Retry-After header")
+	}
+	if len(outbox.messages) != 0 {
+		t.Fatalf("expected no email, got %+v", outbox.messages)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAddFavourite_UnverifiedEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u3", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(unverifiedUserRow("u3", "Carol", "carol@example.com")...))
+
+	body := `{"asset_id":"a1"}`
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/users/u3/favourites", strings.NewReader(body)), "u3")
+	rec := httptest.NewRecorder()
+
+	AddFavourite(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
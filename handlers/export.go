@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+// userExport is everything stored about a user, for data subject access
+// requests.
+type userExport struct {
+	ExportedAt   time.Time               `json:"exported_at"`
+	Profile      *models.User            `json:"profile"`
+	Favourites   []models.FavouriteAsset `json:"favourites"`
+	LoginHistory []models.LoginEvent     `json:"login_history"`
+	AuditLog     []models.AuditEvent     `json:"audit_log"`
+}
+
+// ExportUser returns all data of a user as a JSON download, or a ZIP of one
+// JSON file per section with ?format=zip: GET /users/{userID}/export
+// Exports of other users by admins are recorded in the audit log.
+func ExportUser(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[0] != "users" || parts[2] != "export" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		userID := parts[1]
+
+		if !canAccessUser(r, userID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		claims, ok := claimsFromContext(r.Context())
+		byAdmin := ok && claims.Subject != userID
+
+		scope := models.ScopeFavouritesRead
+		if byAdmin {
+			scope = models.ScopeUsersAdmin
+		}
+		if !requireScope(w, r, scope) {
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "zip" {
+			http.Error(w, "format must be json or zip", http.StatusBadRequest)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		export := userExport{ExportedAt: time.Now().UTC(), Profile: user}
+
+		export.Favourites, err = repositories.ListFavouritesForExport(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch favourites: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		export.LoginHistory, err = repositories.ListLoginHistory(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch login history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		export.AuditLog, err = repositories.ListAuditEvents(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch audit log: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if byAdmin {
+			err := repositories.RecordAuditEvent(r.Context(), db.(*sql.DB), models.AuditEvent{
+				ActorID: claims.Subject,
+				UserID:  userID,
+				Action:  models.AuditUserExport,
+				Detail:  format,
+			})
+			if err != nil {
+				http.Error(w, "Failed to record audit event: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		filename := "user-" + userID + "-export." + format
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(export)
+			return
+		}
+
+		archive, err := zipExport(export)
+		if err != nil {
+			http.Error(w, "Failed to build export: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.WriteHeader(http.StatusOK)
+		w.Write(archive)
+	}
+}
+
+// zipExport packs each section of export into its own JSON file.
+func zipExport(export userExport) ([]byte, error) {
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"favourites.json", export.Favourites},
+		{"login_history.json", export.LoginHistory},
+		{"audit_log.json", export.AuditLog},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	for _, file := range files {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
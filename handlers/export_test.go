@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+// expectExportQueries expects the queries ExportUser runs for userID on
+// behalf of caller, who has role.
+func expectExportQueries(mock sqlmock.Sqlmock, userID, caller, role string) {
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs(userID, "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow(userID, "Bob", "hash", models.RoleMember)...))
+	expectUserTx(mock, caller, role)
+	mock.ExpectQuery("FROM favourites").
+		WithArgs(userID, "default", caller, role == models.RoleAdmin).
+		WillReturnRows(sqlmock.NewRows([]string{"asset_id", "type", "title", "data", "description"}).
+			AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{"x_axis":"month"}`), "my chart"))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM login_history").
+		WithArgs(userID, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "method", "ip_address", "user_agent", "created_at"}).
+			AddRow(1, userID, models.LoginMethodPassword, "192.0.2.1", "curl", now))
+	mock.ExpectQuery("FROM audit_log").
+		WithArgs(userID, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "user_id", "action", "detail", "created_at"}).
+			AddRow(1, "u1", userID, models.AuditImpersonationStart, "", now))
+}
+
+func TestExportUser_JSON(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectExportQueries(mock, "u2", "u2", models.RoleMember)
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/users/u2/export", nil), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	ExportUser(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename=user-u2-export.json` {
+		t.Fatalf("Content-Disposition = %q", got)
+	}
+
+	var export userExport
+	if err := json.NewDecoder(rec.Body).Decode(&export); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if export.Profile == nil || export.Profile.ID != "u2" || len(export.Favourites) != 1 ||
+		len(export.LoginHistory) != 1 || len(export.AuditLog) != 1 {
+		t.Fatalf("unexpected export: %+v", export)
+	}
+	if export.Favourites[0].Description == nil || *export.Favourites[0].Description != "my chart" {
+		t.Fatalf("favourite description missing: %+v", export.Favourites[0])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestExportUser_IncludesFavouritesOfHiddenAssets(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", models.RoleMember)...))
+	// a2 has since been made private by its creator, so only the favourite
+	// itself is returned
+	expectUserTx(mock, "u2", models.RoleMember)
+	mock.ExpectQuery("LEFT JOIN assets a").
+		WithArgs("u2", "default", "u2", false).
+		WillReturnRows(sqlmock.NewRows([]string{"asset_id", "type", "title", "data", "description"}).
+			AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{"x_axis":"month"}`), "my chart").
+			AddRow("a2", "", nil, nil, "hidden now"))
+	mock.ExpectCommit()
+	mock.ExpectQuery("FROM login_history").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "method", "ip_address", "user_agent", "created_at"}))
+	mock.ExpectQuery("FROM audit_log").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "user_id", "action", "detail", "created_at"}))
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/users/u2/export", nil), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	ExportUser(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var export userExport
+	if err := json.NewDecoder(rec.Body).Decode(&export); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(export.Favourites) != 2 {
+		t.Fatalf("expected 2 favourites, got %+v", export.Favourites)
+	}
+	hidden := export.Favourites[1]
+	if hidden.AssetID != "a2" || hidden.Description == nil || *hidden.Description != "hidden now" || hidden.Data != nil {
+		t.Fatalf("unexpected favourite of hidden asset: %+v", hidden)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestExportUser_ZIPByAdminIsAudited(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectExportQueries(mock, "u2", "u1", models.RoleAdmin)
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("u1", "u2", models.AuditUserExport, "zip", "default").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/users/u2/export?format=zip", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	ExportUser(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatalf("response is not a zip: %v", err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	for _, name := range []string{"profile.json", "favourites.json", "login_history.json", "audit_log.json"} {
+		if !json.Valid(files[name]) {
+			t.Fatalf("%s missing or not JSON: %q", name, files[name])
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestExportUser_ForbiddenForOtherUser(t *testing.T) {
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/users/u1/export", nil), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	ExportUser(nil).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 
@@ -22,6 +23,29 @@ func ensureUserExists(ctx context.Context, db *sql.DB, userID string) error {
 	return nil
 }
 
+// errEmailNotVerified keeps users from changing favourites until they have
+// confirmed their email address.
+var errEmailNotVerified = errors.New("Email address not verified")
+
+// ensureUserCanWrite returns an error if the user does not exist or has not
+// verified their email address.
+func ensureUserCanWrite(ctx context.Context, db *sql.DB, userID string) error {
+	user, err := repositories.GetUserByID(ctx, db, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return sql.ErrNoRows
+	}
+	if user.EmailUnverified() {
+		return errEmailNotVerified
+	}
+	return nil
+}
+
+// GetUserFavourites lists the favourites of a user. Within an organization,
+// given by ?org= or the active organization of the token, only favourites of
+// the organization's assets are listed.
 func GetUserFavourites(db DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		parts := strings.Split(r.URL.Path, "/")
@@ -32,6 +56,11 @@ func GetUserFavourites(db DB) http.HandlerFunc {
 
 		userID := parts[2]
 
+		if !canAccessUser(r, userID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
 		if err := ensureUserExists(r.Context(), db.(*sql.DB), userID); err != nil {
 			if err == sql.ErrNoRows {
 				http.Error(w, "user not found", http.StatusNotFound)
@@ -41,10 +70,16 @@ func GetUserFavourites(db DB) http.HandlerFunc {
 			return
 		}
 
+		orgID, ok := orgScope(w, r, db.(*sql.DB))
+		if !ok {
+			return
+		}
+
 		favs, err := repositories.GetUserFavourites(
 			r.Context(),
 			db.(*sql.DB),
 			userID,
+			orgID,
 		)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -61,11 +96,20 @@ func AddFavourite(db DB) http.HandlerFunc {
 		parts := strings.Split(r.URL.Path, "/")
 		userID := parts[2]
 
-		if err := ensureUserExists(r.Context(), db.(*sql.DB), userID); err != nil {
+		if !canAccessUser(r, userID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if err := ensureUserCanWrite(r.Context(), db.(*sql.DB), userID); err != nil {
 			if err == sql.ErrNoRows {
 				http.Error(w, "user not found", http.StatusNotFound)
 				return
 			}
+			if err == errEmailNotVerified {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
 			http.Error(w, "failed to verify user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -87,6 +131,10 @@ func AddFavourite(db DB) http.HandlerFunc {
 			input.AssetID,
 			input.Description,
 		)
+		if errors.Is(err, repositories.ErrAssetNotFound) {
+			http.Error(w, "Asset not found", http.StatusNotFound)
+			return
+		}
 		if err != nil {
 			println("Error adding favourite:", err.Error())
 			http.Error(w, "Failed to add favourite: "+err.Error(), http.StatusInternalServerError)
@@ -109,11 +157,20 @@ func UpdateFavourite(db DB) http.HandlerFunc {
 		userID := parts[2]
 		assetID := parts[4]
 
-		if err := ensureUserExists(r.Context(), db.(*sql.DB), userID); err != nil {
+		if !canAccessUser(r, userID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if err := ensureUserCanWrite(r.Context(), db.(*sql.DB), userID); err != nil {
 			if err == sql.ErrNoRows {
 				http.Error(w, "user not found", http.StatusNotFound)
 				return
 			}
+			if err == errEmailNotVerified {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
 			http.Error(w, "failed to verify user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -149,11 +206,20 @@ func RemoveFavourite(db DB) http.HandlerFunc {
 		userID := parts[2]
 		assetID := parts[4]
 
-		if err := ensureUserExists(r.Context(), db.(*sql.DB), userID); err != nil {
+		if !canAccessUser(r, userID) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if err := ensureUserCanWrite(r.Context(), db.(*sql.DB), userID); err != nil {
 			if err == sql.ErrNoRows {
 				http.Error(w, "user not found", http.StatusNotFound)
 				return
 			}
+			if err == errEmailNotVerified {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
 			http.Error(w, "failed to verify user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -2,15 +2,18 @@ package handlers
 
 import (
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"platform-go-challenge/models"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func TestGetUserFavourites_InvalidPath(t *testing.T) {
@@ -37,10 +40,10 @@ func TestGetUserFavourites_UserNotFound(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("missing").
+		WithArgs("missing", "default").
 		WillReturnError(sql.ErrNoRows)
 
-	req := httptest.NewRequest(http.MethodGet, "/users/missing/favourites", nil)
+	req := withSubject(httptest.NewRequest(http.MethodGet, "/users/missing/favourites", nil), "missing")
 	rec := httptest.NewRecorder()
 
 	handler := GetUserFavourites(db)
@@ -67,17 +70,19 @@ func TestGetUserFavourites_Success(t *testing.T) {
 
 	// User exists
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-			AddRow("u1", "Alice", "hash"))
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
 
 	// Get favourites
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectQuery("SELECT").
-		WithArgs("u1").
+		WithArgs("u1", "", "default", "u1", false).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "title", "data", "description"}).
 			AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{}`), nil))
+	mock.ExpectCommit()
 
-	req := httptest.NewRequest(http.MethodGet, "/users/u1/favourites", nil)
+	req := withSubject(httptest.NewRequest(http.MethodGet, "/users/u1/favourites", nil), "u1")
 	rec := httptest.NewRecorder()
 
 	handler := GetUserFavourites(db)
@@ -123,11 +128,11 @@ func TestAddFavourite_UserNotFound(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("missing").
+		WithArgs("missing", "default").
 		WillReturnError(sql.ErrNoRows)
 
 	body := `{"asset_id":"a1","description":"My fav"}`
-	req := httptest.NewRequest(http.MethodPost, "/users/missing/favourites", strings.NewReader(body))
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/users/missing/favourites", strings.NewReader(body)), "missing")
 	rec := httptest.NewRecorder()
 
 	handler := AddFavourite(db)
@@ -153,11 +158,11 @@ func TestAddFavourite_InvalidBody(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-			AddRow("u1", "Alice", "hash"))
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
 
-	req := httptest.NewRequest(http.MethodPost, "/users/u1/favourites", strings.NewReader("{"))
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/users/u1/favourites", strings.NewReader("{")), "u1")
 	rec := httptest.NewRecorder()
 
 	handler := AddFavourite(db)
@@ -184,17 +189,19 @@ func TestAddFavourite_Success(t *testing.T) {
 
 	// User exists
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-			AddRow("u1", "Alice", "hash"))
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
 
 	// Add favourite
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("INSERT INTO favourites").
-		WithArgs("u1", "a1", nil).
+		WithArgs("u1", "a1", nil, "default", "u1", false).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
 	body := `{"asset_id":"a1"}`
-	req := httptest.NewRequest(http.MethodPost, "/users/u1/favourites", strings.NewReader(body))
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/users/u1/favourites", strings.NewReader(body)), "u1")
 	rec := httptest.NewRecorder()
 
 	handler := AddFavourite(db)
@@ -217,6 +224,40 @@ func TestAddFavourite_Success(t *testing.T) {
 	}
 }
 
+func TestAddFavourite_AssetNotVisible(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
+
+	// The asset is private to another user, so nothing is inserted
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectExec("INSERT INTO favourites").
+		WithArgs("u1", "a2", nil, "default", "u1", false).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	body := `{"asset_id":"a2"}`
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/users/u1/favourites", strings.NewReader(body)), "u1")
+	rec := httptest.NewRecorder()
+
+	AddFavourite(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestUpdateFavourite_UserNotFound(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -225,11 +266,11 @@ func TestUpdateFavourite_UserNotFound(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("missing").
+		WithArgs("missing", "default").
 		WillReturnError(sql.ErrNoRows)
 
 	body := `{"description":"Updated"}`
-	req := httptest.NewRequest(http.MethodPatch, "/users/missing/favourites/a1", strings.NewReader(body))
+	req := withSubject(httptest.NewRequest(http.MethodPatch, "/users/missing/favourites/a1", strings.NewReader(body)), "missing")
 	rec := httptest.NewRecorder()
 
 	handler := UpdateFavourite(db)
@@ -252,11 +293,11 @@ func TestUpdateFavourite_InvalidBody(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-			AddRow("u1", "Alice", "hash"))
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
 
-	req := httptest.NewRequest(http.MethodPatch, "/users/u1/favourites/a1", strings.NewReader("{"))
+	req := withSubject(httptest.NewRequest(http.MethodPatch, "/users/u1/favourites/a1", strings.NewReader("{")), "u1")
 	rec := httptest.NewRecorder()
 
 	handler := UpdateFavourite(db)
@@ -280,17 +321,19 @@ func TestUpdateFavourite_Success(t *testing.T) {
 
 	// User exists
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-			AddRow("u1", "Alice", "hash"))
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
 
 	// Update favourite
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("UPDATE favourites").
-		WithArgs("u1", "a1", "Updated desc").
+		WithArgs("u1", "a1", "Updated desc", "default").
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
 	body := `{"description":"Updated desc"}`
-	req := httptest.NewRequest(http.MethodPatch, "/users/u1/favourites/a1", strings.NewReader(body))
+	req := withSubject(httptest.NewRequest(http.MethodPatch, "/users/u1/favourites/a1", strings.NewReader(body)), "u1")
 	rec := httptest.NewRecorder()
 
 	handler := UpdateFavourite(db)
@@ -313,10 +356,10 @@ func TestRemoveFavourite_UserNotFound(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("missing").
+		WithArgs("missing", "default").
 		WillReturnError(sql.ErrNoRows)
 
-	req := httptest.NewRequest(http.MethodDelete, "/users/missing/favourites/a1", nil)
+	req := withSubject(httptest.NewRequest(http.MethodDelete, "/users/missing/favourites/a1", nil), "missing")
 	rec := httptest.NewRecorder()
 
 	handler := RemoveFavourite(db)
@@ -340,16 +383,18 @@ func TestRemoveFavourite_NotFound(t *testing.T) {
 
 	// User exists
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-			AddRow("u1", "Alice", "hash"))
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
 
 	// Delete favourite (no rows affected)
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("DELETE FROM favourites").
-		WithArgs("u1", "a1").
+		WithArgs("u1", "a1", "default").
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
 
-	req := httptest.NewRequest(http.MethodDelete, "/users/u1/favourites/a1", nil)
+	req := withSubject(httptest.NewRequest(http.MethodDelete, "/users/u1/favourites/a1", nil), "u1")
 	rec := httptest.NewRecorder()
 
 	handler := RemoveFavourite(db)
@@ -373,16 +418,18 @@ func TestRemoveFavourite_Success(t *testing.T) {
 
 	// User exists
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-			AddRow("u1", "Alice", "hash"))
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
 
 	// Delete favourite (1 row affected)
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("DELETE FROM favourites").
-		WithArgs("u1", "a1").
+		WithArgs("u1", "a1", "default").
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	req := httptest.NewRequest(http.MethodDelete, "/users/u1/favourites/a1", nil)
+	req := withSubject(httptest.NewRequest(http.MethodDelete, "/users/u1/favourites/a1", nil), "u1")
 	rec := httptest.NewRecorder()
 
 	handler := RemoveFavourite(db)
@@ -396,3 +443,162 @@ func TestRemoveFavourite_Success(t *testing.T) {
 		t.Fatalf("unmet expectations: %v", err)
 	}
 }
+
+// withSubject attaches token claims for subject, as AuthMiddleware would.
+func withSubject(req *http.Request, subject string) *http.Request {
+	return withClaims(req, subject, models.RoleMember)
+}
+
+// userColumns are the columns of the users queries in repositories.
+var userColumns = []string{"id", "name", "password_hash", "role", "disabled_at", "email",
+	"display_name", "avatar_url", "locale", "created_at", "updated_at", "last_login_at", "email_verified_at", "deleted_at", "tenant_id"}
+
+// userRow returns a users row for an active user without profile fields.
+func userRow(id, name, passwordHash, role string) []driver.Value {
+	now := time.Now()
+	return []driver.Value{id, name, passwordHash, role, nil, "", "", "", "", now, now, nil, nil, nil, "default"}
+}
+
+// expectUserTx expects the transaction in which the favourites of a user are
+// read or written, with subject and role as the current user.
+func expectUserTx(mock sqlmock.Sqlmock, subject, role string) {
+	mock.ExpectBegin()
+	mock.ExpectExec("set_config").
+		WithArgs(subject, role).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// disabledUserRow returns a users row for a disabled user.
+func disabledUserRow(id, name, passwordHash, role string) []driver.Value {
+	row := userRow(id, name, passwordHash, role)
+	row[4] = time.Now()
+	return row
+}
+
+// withClaims attaches token claims for subject with the given role, as
+// AuthMiddleware does.
+func withClaims(req *http.Request, subject, role string) *http.Request {
+	claims := &Claims{Role: role, RegisteredClaims: jwt.RegisteredClaims{Subject: subject}}
+	return req.WithContext(contextWithClaims(req.Context(), claims))
+}
+
+func TestFavourites_ForbiddenForOtherUser(t *testing.T) {
+	cases := map[string]struct {
+		method  string
+		path    string
+		handler func(DB) http.HandlerFunc
+	}{
+		"get":    {http.MethodGet, "/users/u2/favourites", GetUserFavourites},
+		"add":    {http.MethodPost, "/users/u2/favourites", AddFavourite},
+		"update": {http.MethodPatch, "/users/u2/favourites/a1", UpdateFavourite},
+		"remove": {http.MethodDelete, "/users/u2/favourites/a1", RemoveFavourite},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			// No DB expectations: the ownership check must run first
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New error: %v", err)
+			}
+			defer db.Close()
+
+			req := withSubject(httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}")), "u1")
+			rec := httptest.NewRecorder()
+
+			tt.handler(db).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestFavourites_MissingClaimsForbidden(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/u1/favourites", nil)
+	rec := httptest.NewRecorder()
+
+	var mockDB *sql.DB
+	GetUserFavourites(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestFavourites_AdminOverride(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", "member")...))
+
+	// Admins pass the row-level security policy of favourites
+	expectUserTx(mock, "admin", models.RoleAdmin)
+	mock.ExpectQuery("SELECT").
+		WithArgs("u2", "", "default", "admin", true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "title", "data", "description"}))
+	mock.ExpectCommit()
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/users/u2/favourites", nil), "admin", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	GetUserFavourites(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAuthMiddleware_FavouritesRunAsTokenSubject(t *testing.T) {
+	origAuthEnabled, origSecret := authEnabled, jwtSecret
+	defer func() { authEnabled, jwtSecret = origAuthEnabled, origSecret }()
+	authEnabled = true
+	jwtSecret = []byte("test-secret")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	token, err := IssueToken(&models.User{ID: "u2", Role: models.RoleMember})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", "member")...))
+	expectUserTx(mock, "u2", models.RoleMember)
+	mock.ExpectQuery("SELECT").
+		WithArgs("u2", "", "default", "u2", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "title", "data", "description"}))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest(http.MethodGet, "/users/u2/favourites", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	AuthMiddleware(GetUserFavourites(db)).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+var impersonationTTL = 15 * time.Minute
+
+func init() {
+	// Impersonation tokens are short-lived, e.g. IMPERSONATION_TTL=5m
+	if ttl, err := time.ParseDuration(os.Getenv("IMPERSONATION_TTL")); err == nil && ttl > 0 {
+		impersonationTTL = ttl
+	}
+}
+
+// auditDB is nil until EnableAuditLog is called, in which case requests
+// made while impersonating are recorded in the audit log.
+var auditDB *sql.DB
+
+var errImpersonating = errors.New("Not allowed while impersonating")
+
+// EnableAuditLog records impersonated requests in db.
+func EnableAuditLog(db *sql.DB) {
+	auditDB = db
+}
+
+type impersonationResponse struct {
+	Token     string `json:"token"`
+	TokenType string `json:"token_type"`
+	ExpiresIn int    `json:"expires_in"`
+	Scope     string `json:"scope"`
+	Act       *Actor `json:"act"`
+}
+
+// rejectImpersonation writes a 403 and returns true when the caller is
+// impersonating someone. Credentials may only be changed by their owner.
+func rejectImpersonation(w http.ResponseWriter, r *http.Request) bool {
+	if claims, ok := claimsFromContext(r.Context()); ok && claims.Act != nil {
+		http.Error(w, errImpersonating.Error(), http.StatusForbidden)
+		return true
+	}
+	return false
+}
+
+// statusRecorder remembers the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// auditImpersonation logs every request made with an impersonation token
+// together with the admin behind it. Requests that change state are also
+// recorded in the audit log first, and refused if that fails.
+func auditImpersonation(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := claimsFromContext(r.Context())
+
+		if !safeMethod(r.Method) && auditDB != nil {
+			err := repositories.RecordAuditEvent(r.Context(), auditDB, models.AuditEvent{
+				ActorID: claims.Act.Subject,
+				UserID:  claims.Subject,
+				Action:  models.AuditImpersonationRequest,
+				Detail:  r.Method + " " + r.URL.Path,
+			})
+			if err != nil {
+				http.Error(w, "Failed to record audit event: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		log.Printf("impersonation: act=%s sub=%s %s %s %d", claims.Act.Subject, claims.Subject, r.Method, r.URL.Path, rec.status)
+	}
+}
+
+// ImpersonateUser issues a short-lived access token acting as another user,
+// so support can see what they see: POST /users/{userID}/impersonate
+// The token names the admin in its act claim and comes without a refresh token.
+func ImpersonateUser(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !requireScope(w, r, models.ScopeUsersAdmin) {
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[0] != "users" || parts[2] != "impersonate" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		userID := parts[1]
+
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if rejectImpersonation(w, r) {
+			return
+		}
+
+		if userID == claims.Subject {
+			http.Error(w, "Cannot impersonate yourself", http.StatusBadRequest)
+			return
+		}
+
+		var input struct {
+			Reason string `json:"reason"`
+			Scope  string `json:"scope"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		if user.Role == models.RoleAdmin {
+			http.Error(w, "Cannot impersonate an admin", http.StatusForbidden)
+			return
+		}
+		if user.DisabledAt != nil || user.DeletedAt != nil {
+			http.Error(w, "Account is disabled or deleted", http.StatusConflict)
+			return
+		}
+
+		scope, err := grantScope(input.Scope, user.Role)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		act := &Actor{Subject: claims.Subject}
+		impersonation := accessClaims(user, scope)
+		impersonation.Act = act
+		token, err := signAccessToken(impersonation, impersonationTTL)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		// No audit record, no token
+		err = repositories.RecordAuditEvent(r.Context(), db.(*sql.DB), models.AuditEvent{
+			ActorID: act.Subject,
+			UserID:  user.ID,
+			Action:  models.AuditImpersonationStart,
+			Detail:  strings.TrimSpace(input.Reason),
+		})
+		if err != nil {
+			http.Error(w, "Failed to record audit event: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("impersonation: act=%s sub=%s started", act.Subject, user.ID)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(impersonationResponse{
+			Token:     token,
+			TokenType: "Bearer",
+			ExpiresIn: int(impersonationTTL.Seconds()),
+			Scope:     scope,
+			Act:       act,
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+// useAuditLog records impersonated requests in db for the test.
+func useAuditLog(t *testing.T, db *sql.DB) {
+	t.Helper()
+
+	orig := auditDB
+	t.Cleanup(func() { auditDB = orig })
+	EnableAuditLog(db)
+}
+
+// impersonationToken signs a token for subject issued to admin.
+func impersonationToken(t *testing.T, subject, admin string) string {
+	t.Helper()
+
+	claims := accessClaims(&models.User{ID: subject, Role: models.RoleMember}, "favourites:read favourites:write")
+	claims.Act = &Actor{Subject: admin}
+	token, err := signAccessToken(claims, impersonationTTL)
+	if err != nil {
+		t.Fatalf("signAccessToken error: %v", err)
+	}
+	return token
+}
+
+func TestImpersonateUser_IssuesTokenWithActClaim(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", models.RoleMember)...))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("u1", "u2", models.AuditImpersonationStart, "ticket 42", "default").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	body := `{"reason":"ticket 42"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/users/u2/impersonate", strings.NewReader(body)), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	ImpersonateUser(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp impersonationResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	claims, err := parseAccessToken(resp.Token)
+	if err != nil {
+		t.Fatalf("issued token does not verify: %v", err)
+	}
+	if claims.Subject != "u2" || claims.Role != models.RoleMember || claims.Act == nil || claims.Act.Subject != "u1" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+	if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); lifetime != impersonationTTL {
+		t.Fatalf("token lifetime = %s, want %s", lifetime, impersonationTTL)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestImpersonateUser_RejectsAdmins(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u3", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u3", "Carol", "hash", models.RoleAdmin)...))
+
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/users/u3/impersonate", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	ImpersonateUser(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestImpersonation_CannotChangeCredentials(t *testing.T) {
+	token := impersonationToken(t, "u2", "u1")
+
+	cases := map[string]struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		"password":   {"/me/password", ChangePassword(nil)},
+		"2fa enroll": {"/me/2fa/enroll", EnrollTwoFactor(nil)},
+		"2fa verify": {"/me/2fa/verify", VerifyTwoFactor(nil)},
+		"api key":    {"/users/u2/api-keys", CreateAPIKey(nil, "u2")},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+
+			AuthMiddleware(tc.handler).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_AuditsImpersonatedWrites(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	useAuditLog(t, db)
+	token := impersonationToken(t, "u2", "u1")
+
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("u1", "u2", models.AuditImpersonationRequest, "DELETE /users/u2/favourites/a1", "default").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/users/u2/favourites/a1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s status = %d, want %d", method, rec.Code, http.StatusNoContent)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAuthMiddleware_ImpersonationDoesNotLeakIntoLaterRequests(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	useAuditLog(t, db)
+	impersonated := impersonationToken(t, "u2", "u1")
+	normal, err := IssueToken(&models.User{ID: "u3", Role: models.RoleMember})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	// Only the impersonated request is audited
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("u1", "u2", models.AuditImpersonationRequest, "DELETE /users/u2/favourites/a1", "default").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	handler := AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	for _, tc := range []struct{ token, path string }{
+		{impersonated, "/users/u2/favourites/a1"},
+		{normal, "/users/u3/favourites/a1"},
+	} {
+		req := httptest.NewRequest(http.MethodDelete, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("%s status = %d, want %d", tc.path, rec.Code, http.StatusNoContent)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"platform-go-challenge/mail"
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+// publicRegistration lets anyone use POST /register. When false, only
+// holders of an invitation can register.
+var publicRegistration = true
+
+var invitationTTL = 7 * 24 * time.Hour
+
+func init() {
+	// Open sign-up is on unless PUBLIC_REGISTRATION=false; invitations
+	// work either way, e.g. INVITATION_TTL=72h
+	publicRegistration = os.Getenv("PUBLIC_REGISTRATION") != "false"
+	if ttl, err := time.ParseDuration(os.Getenv("INVITATION_TTL")); err == nil && ttl > 0 {
+		invitationTTL = ttl
+	}
+}
+
+// invitationCreatedResponse is returned once when an invitation is created;
+// the code cannot be retrieved later.
+type invitationCreatedResponse struct {
+	models.Invitation
+	Code string `json:"code"`
+}
+
+// InvitationsRouter manages invitations:
+// GET /invitations, POST /invitations and DELETE /invitations/{invitationID}
+func InvitationsRouter(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		if parts[0] != "invitations" || len(parts) > 2 {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !requireScope(w, r, models.ScopeUsersAdmin) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if len(parts) == 1 {
+				ListInvitations(db)(w, r)
+				return
+			}
+
+		case http.MethodPost:
+			if len(parts) == 1 {
+				CreateInvitation(db)(w, r)
+				return
+			}
+
+		case http.MethodDelete:
+			if len(parts) == 2 {
+				RevokeInvitation(db, parts[1])(w, r)
+				return
+			}
+		}
+
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func ListInvitations(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invitations, err := repositories.ListInvitations(r.Context(), db.(*sql.DB))
+		if err != nil {
+			http.Error(w, "Failed to fetch invitations: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(invitations)
+	}
+}
+
+// CreateInvitation issues a single-use invitation code, emailed to the
+// invitee when an email is given.
+func CreateInvitation(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Email     string     `json:"email"`
+			Role      string     `json:"role"`
+			ExpiresAt *time.Time `json:"expires_at"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		input.Email = strings.TrimSpace(input.Email)
+		if input.Email != "" && !models.ValidEmail(input.Email) {
+			http.Error(w, "Invalid email", http.StatusBadRequest)
+			return
+		}
+
+		if input.Role == "" {
+			input.Role = models.RoleMember
+		}
+		if !models.ValidRole(input.Role) {
+			http.Error(w, "Role must be admin or member", http.StatusBadRequest)
+			return
+		}
+
+		expiresAt := time.Now().Add(invitationTTL)
+		if input.ExpiresAt != nil {
+			if !input.ExpiresAt.After(time.Now()) {
+				http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+				return
+			}
+			expiresAt = *input.ExpiresAt
+		}
+
+		inUse, err := emailInUse(r, db.(*sql.DB), input.Email)
+		if err != nil {
+			http.Error(w, "Failed to create invitation: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if inUse {
+			http.Error(w, "Email already in use", http.StatusConflict)
+			return
+		}
+
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			http.Error(w, "Failed to generate invitation", http.StatusInternalServerError)
+			return
+		}
+
+		code, err := generateOpaqueToken()
+		if err != nil {
+			http.Error(w, "Failed to generate invitation", http.StatusInternalServerError)
+			return
+		}
+
+		var createdBy string
+		if claims, ok := claimsFromContext(r.Context()); ok {
+			createdBy = claims.Subject
+		}
+
+		inv, err := repositories.CreateInvitation(r.Context(), db.(*sql.DB), models.Invitation{
+			ID:        hex.EncodeToString(id),
+			CodeHash:  hashOpaqueToken(code),
+			Email:     input.Email,
+			Role:      input.Role,
+			CreatedBy: createdBy,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			http.Error(w, "Failed to create invitation: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if inv.Email != "" {
+			err := mailer.Send(r.Context(), mail.Message{
+				To:      inv.Email,
+				Subject: "You have been invited",
+				Body: fmt.Sprintf(
+					"Hi,\n\nYou have been invited to create an account. Register with this email address and the code below before %s.\n\nInvitation code: %s\n",
+					inv.ExpiresAt.UTC().Format(time.RFC1123), code,
+				),
+			})
+			if err != nil {
+				println("Error sending invitation:", err.Error())
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(invitationCreatedResponse{Invitation: *inv, Code: code})
+	}
+}
+
+// RevokeInvitation stops an unused invitation from being used.
+func RevokeInvitation(db DB, invitationID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := repositories.RevokeInvitation(r.Context(), db.(*sql.DB), invitationID)
+		if errors.Is(err, repositories.ErrInvitationNotFound) {
+			http.Error(w, "invitation not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to revoke invitation: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+var invitationColumns = []string{"id", "code_hash", "email", "role", "created_by", "expires_at", "used_at", "used_by", "revoked_at", "created_at"}
+
+// useClosedRegistration turns public registration off for the test.
+func useClosedRegistration(t *testing.T) {
+	t.Helper()
+
+	orig := publicRegistration
+	t.Cleanup(func() { publicRegistration = orig })
+	publicRegistration = false
+}
+
+func TestCreateInvitation_ReturnsAndEmailsCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	outbox := useRecordingMailer(t)
+
+	var codeHash string
+	now := time.Now()
+	mock.ExpectQuery("WHERE lower\\(email\\)").
+		WithArgs("carol@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectQuery("INSERT INTO invitations").
+		WithArgs(sqlmock.AnyArg(), capture{&codeHash}, "carol@example.com", models.RoleAdmin, "u1", sqlmock.AnyArg(), "default").
+		WillReturnRows(sqlmock.NewRows(invitationColumns).
+			AddRow("i1", "hash", "carol@example.com", models.RoleAdmin, "u1", now.Add(invitationTTL), nil, "", nil, now))
+
+	body := `{"email":"carol@example.com","role":"admin"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/invitations", strings.NewReader(body)), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	InvitationsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	code, _ := resp["code"].(string)
+	if code == "" || hashOpaqueToken(code) != codeHash {
+		t.Fatalf("response code %q does not match the stored hash", code)
+	}
+	if _, ok := resp["CodeHash"]; ok {
+		t.Fatalf("code hash must not be returned: %v", resp)
+	}
+
+	if len(outbox.messages) != 1 || !strings.Contains(outbox.messages[0].Body, code) {
+		t.Fatalf("expected the code to be emailed to carol, got %+v", outbox.messages)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevokeInvitation_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE invitations").
+		WithArgs("i1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := withClaims(httptest.NewRequest(http.MethodDelete, "/invitations/i1", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	InvitationsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRegister_InvitationOnly(t *testing.T) {
+	useClosedRegistration(t)
+
+	body := `{"id":"u3","name":"Carol","password":"carol123","email":"carol@example.com"}`
+	rec := httptest.NewRecorder()
+	Register(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestRegister_WithInvitation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	useClosedRegistration(t)
+	outbox := useRecordingMailer(t)
+
+	now := time.Now()
+	mock.ExpectQuery("WHERE lower\\(email\\)").
+		WithArgs("carol@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, code_hash").
+		WithArgs(hashOpaqueToken("invite-1"), "carol@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(invitationColumns).
+			AddRow("i1", "hash", "carol@example.com", models.RoleAdmin, "u1", now.Add(time.Hour), nil, "", nil, now))
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs("u3", "Carol", sqlmock.AnyArg(), models.RoleAdmin, "carol@example.com", "", "", "", true, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE invitations").
+		WithArgs("i1", "u3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{"id":"u3","name":"Carol","password":"carol123","email":"carol@example.com","invitation_code":"invite-1"}`
+	rec := httptest.NewRecorder()
+	Register(db).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["role"] != models.RoleAdmin || resp["email_verified"] != true {
+		t.Fatalf("unexpected response: %v", resp)
+	}
+	if len(outbox.messages) != 0 {
+		t.Fatalf("expected no verification email, got %+v", outbox.messages)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRegister_InvalidInvitation(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("WHERE lower\\(email\\)").
+		WithArgs("carol@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, code_hash").
+		WithArgs(hashOpaqueToken("used"), "carol@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(invitationColumns))
+	mock.ExpectRollback()
+
+	body := `{"id":"u3","name":"Carol","password":"carol123","email":"carol@example.com","invitation_code":"used"}`
+	rec := httptest.NewRecorder()
+	Register(db).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
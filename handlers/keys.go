@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signingKey is an asymmetric key used to sign and/or verify access tokens.
+// Keys loaded from a public key PEM can only verify.
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// signingKeys holds every key accepted for verification, by kid. When empty,
+// tokens are signed and verified with the HMAC jwtSecret instead.
+var signingKeys map[string]*signingKey
+
+// activeKey is the key new tokens are signed with.
+var activeKey *signingKey
+
+var errUnexpectedSigningMethod = errors.New("unexpected signing method")
+
+// loadSigningKeys reads every *.pem file in dir as a signing key named after
+// the file (e.g. 2026-01.pem has kid "2026-01"). activeKID selects the
+// private key used for signing; it may be empty when dir holds exactly one
+// private key. Keeping the previous key's PEM in dir after a rotation lets
+// tokens it signed verify until they expire.
+func loadSigningKeys(dir, activeKID string) (map[string]*signingKey, *signingKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no .pem keys found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	keys := make(map[string]*signingKey)
+	var private []*signingKey
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseSigningKey(kid, raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		keys[kid] = key
+		if key.private != nil {
+			private = append(private, key)
+		}
+	}
+
+	var active *signingKey
+	switch {
+	case activeKID != "":
+		active = keys[activeKID]
+		if active == nil || active.private == nil {
+			return nil, nil, fmt.Errorf("active key %q is not a private key in %s", activeKID, dir)
+		}
+	case len(private) == 1:
+		active = private[0]
+	default:
+		return nil, nil, fmt.Errorf("found %d private keys in %s, set JWT_ACTIVE_KID", len(private), dir)
+	}
+
+	return keys, active, nil
+}
+
+// parseSigningKey parses a PKCS#8/PKCS#1 private key or PKIX public key.
+// RSA keys sign with RS256 and Ed25519 keys with EdDSA.
+func parseSigningKey(kid string, raw []byte) (*signingKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	if rsaKey, ok := key.public.(*rsa.PublicKey); ok && rsaKey.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+
+	return key, nil
+}
+
+// signToken signs claims with the active key, or HS256 when none is set.
+func signToken(claims jwt.Claims) (string, error) {
+	if activeKey == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	}
+
+	token := jwt.NewWithClaims(activeKey.method, claims)
+	token.Header["kid"] = activeKey.kid
+	return token.SignedString(activeKey.private)
+}
+
+// verificationKey is the jwt.Keyfunc used to validate incoming tokens.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if len(signingKeys) == 0 {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errUnexpectedSigningMethod
+		}
+		return jwtSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := signingKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errUnexpectedSigningMethod
+	}
+
+	return key.public, nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS godoc
+// @Summary JSON Web Key Set
+// @Description Returns the public keys used to verify access tokens
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /.well-known/jwks.json [get]
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	kids := make([]string, 0, len(signingKeys))
+	for kid := range signingKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := make([]jwk, 0, len(kids))
+	for _, kid := range kids {
+		key := signingKeys[kid]
+		entry := jwk{Kid: kid, Alg: key.method.Alg(), Use: "sig"}
+
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			entry.Kty = "RSA"
+			entry.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			entry.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			entry.Kty = "OKP"
+			entry.Crv = "Ed25519"
+			entry.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+
+		keys = append(keys, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string][]jwk{"keys": keys})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"platform-go-challenge/models"
+)
+
+// writePEM writes key to dir/<kid>.pem, as a public key when public is set.
+func writePEM(t *testing.T, dir, kid string, key any, public bool) {
+	t.Helper()
+
+	var block *pem.Block
+	if public {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("marshal public key: %v", err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("marshal private key: %v", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write pem: %v", err)
+	}
+}
+
+// useSigningKeys installs the keys in dir for the duration of the test.
+func useSigningKeys(t *testing.T, dir, activeKID string) {
+	t.Helper()
+
+	origKeys, origActive, origAuthEnabled := signingKeys, activeKey, authEnabled
+	t.Cleanup(func() {
+		signingKeys, activeKey, authEnabled = origKeys, origActive, origAuthEnabled
+	})
+
+	keys, active, err := loadSigningKeys(dir, activeKID)
+	if err != nil {
+		t.Fatalf("loadSigningKeys error: %v", err)
+	}
+	signingKeys, activeKey, authEnabled = keys, active, true
+}
+
+func authenticate(token string) int {
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	AuthMiddleware(next).ServeHTTP(rec, req)
+
+	return rec.Code
+}
+
+func TestSigningKeys_RS256AndEdDSA(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+
+	for kid, key := range map[string]any{"rsa": rsaKey, "ed": edKey} {
+		t.Run(kid, func(t *testing.T) {
+			dir := t.TempDir()
+			writePEM(t, dir, kid, key, false)
+			useSigningKeys(t, dir, "")
+
+			token, err := IssueToken(&models.User{ID: "u1", Role: models.RoleMember})
+			if err != nil {
+				t.Fatalf("IssueToken error: %v", err)
+			}
+
+			if code := authenticate(token); code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", code, http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestSigningKeys_RotationKeepsOldTokensValid(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+
+	dir := t.TempDir()
+	writePEM(t, dir, "old", oldKey, false)
+	useSigningKeys(t, dir, "old")
+
+	oldToken, err := IssueToken(&models.User{ID: "u1"})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	// Rotate: the old key is kept for verification only
+	writePEM(t, dir, "old", &oldKey.PublicKey, true)
+	writePEM(t, dir, "new", newKey, false)
+	useSigningKeys(t, dir, "new")
+
+	newToken, err := IssueToken(&models.User{ID: "u1"})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	if code := authenticate(oldToken); code != http.StatusOK {
+		t.Fatalf("old token status = %d, want %d", code, http.StatusOK)
+	}
+	if code := authenticate(newToken); code != http.StatusOK {
+		t.Fatalf("new token status = %d, want %d", code, http.StatusOK)
+	}
+
+	// Once the old key is removed its tokens are rejected
+	if err := os.Remove(filepath.Join(dir, "old.pem")); err != nil {
+		t.Fatalf("remove old key: %v", err)
+	}
+	useSigningKeys(t, dir, "new")
+
+	if code := authenticate(oldToken); code != http.StatusUnauthorized {
+		t.Fatalf("old token status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestSigningKeys_RejectsHMACWhenAsymmetric(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+
+	dir := t.TempDir()
+	writePEM(t, dir, "ed", edKey, false)
+
+	// Token signed with the shared secret before keys are configured
+	hmacToken, err := IssueToken(&models.User{ID: "u1"})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	useSigningKeys(t, dir, "")
+
+	if code := authenticate(hmacToken); code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestLoadSigningKeys_AmbiguousActiveKey(t *testing.T) {
+	_, key1, _ := ed25519.GenerateKey(rand.Reader)
+	_, key2, _ := ed25519.GenerateKey(rand.Reader)
+
+	dir := t.TempDir()
+	writePEM(t, dir, "a", key1, false)
+	writePEM(t, dir, "b", key2, false)
+
+	if _, _, err := loadSigningKeys(dir, ""); err == nil {
+		t.Fatal("expected error for multiple private keys without an active kid")
+	}
+	if _, active, err := loadSigningKeys(dir, "b"); err != nil || active.kid != "b" {
+		t.Fatalf("expected active key b, got %v, %v", active, err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate rsa key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+
+	dir := t.TempDir()
+	writePEM(t, dir, "rsa", &rsaKey.PublicKey, true)
+	writePEM(t, dir, "ed", edKey, false)
+	useSigningKeys(t, dir, "ed")
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	rec := httptest.NewRecorder()
+	JWKS(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Keys) != 2 {
+		t.Fatalf("expected 2 keys, got %d", len(resp.Keys))
+	}
+
+	byKid := map[string]jwk{}
+	for _, k := range resp.Keys {
+		byKid[k.Kid] = k
+	}
+	if k := byKid["rsa"]; k.Kty != "RSA" || k.Alg != "RS256" || k.N == "" || k.E != "AQAB" {
+		t.Fatalf("unexpected rsa jwk: %+v", k)
+	}
+	if k := byKid["ed"]; k.Kty != "OKP" || k.Crv != "Ed25519" || k.Alg != "EdDSA" || k.X == "" {
+		t.Fatalf("unexpected ed25519 jwk: %+v", k)
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+// Failed logins are counted per account and per client IP. Once a counter
+// reaches its threshold the key is locked for loginLockoutBase, doubling with
+// every further failure up to loginLockoutMax. Counters reset after a
+// successful login (account only) or loginFailureWindow without failures.
+var (
+	loginMaxFailures   = 5
+	loginIPMaxFailures = 20
+	loginLockoutBase   = time.Minute
+	loginLockoutMax    = time.Hour
+	loginFailureWindow = 24 * time.Hour
+)
+
+func init() {
+	// Login lockout thresholds, e.g. LOGIN_MAX_FAILURES=5 LOGIN_LOCKOUT_BASE=1m
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES")); err == nil && n > 0 {
+		loginMaxFailures = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_IP_MAX_FAILURES")); err == nil && n > 0 {
+		loginIPMaxFailures = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_BASE")); err == nil && d > 0 {
+		loginLockoutBase = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_MAX")); err == nil && d > 0 {
+		loginLockoutMax = d
+	}
+}
+
+// EnableLoginFailurePurge deletes counters without a failure for
+// loginFailureWindow every interval until ctx is cancelled, so that failures
+// for unknown accounts and one-off IPs do not pile up.
+func EnableLoginFailurePurge(ctx context.Context, db *sql.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purged, err := repositories.PurgeLoginFailures(ctx, db, time.Now().Add(-loginFailureWindow))
+				if err != nil {
+					log.Printf("failed to purge login failures: %v", err)
+				} else if purged > 0 {
+					log.Printf("purged %d login failure counters", purged)
+				}
+			}
+		}
+	}()
+}
+
+func accountLockoutKey(userID string) string { return "user:" + userID }
+func ipLockoutKey(ip string) string          { return "ip:" + ip }
+
+// clientIP returns the address of the directly connected client.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// recordLogin adds a successful login of userID with method to the login
+// history.
+func recordLogin(r *http.Request, db *sql.DB, userID, method string) error {
+	return repositories.RecordUserLogin(r.Context(), db, models.LoginEvent{
+		UserID:    userID,
+		Method:    method,
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+}
+
+// lockoutDelay returns how long a key with failures failures stays locked.
+func lockoutDelay(failures, threshold int) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	exp := failures - threshold
+	if exp > 30 {
+		return loginLockoutMax
+	}
+
+	delay := loginLockoutBase * time.Duration(1<<exp)
+	if delay <= 0 || delay > loginLockoutMax {
+		return loginLockoutMax
+	}
+	return delay
+}
+
+// lockedFor returns the remaining lock time for key, or 0 when it is unlocked.
+func lockedFor(ctx context.Context, db *sql.DB, key string) (time.Duration, error) {
+	failure, err := repositories.GetLoginFailure(ctx, db, key)
+	if err != nil || failure == nil || failure.LockedUntil == nil {
+		return 0, err
+	}
+
+	return time.Until(*failure.LockedUntil), nil
+}
+
+// checkLoginLockout rejects the request with 429 when the client IP is
+// backing off or 423 when the account is locked. It returns false if a
+// response was written.
+func checkLoginLockout(w http.ResponseWriter, r *http.Request, db *sql.DB, userID string) bool {
+	checks := []struct {
+		key     string
+		status  int
+		message string
+	}{
+		{ipLockoutKey(clientIP(r)), http.StatusTooManyRequests, "Too many failed login attempts"},
+		{accountLockoutKey(userID), http.StatusLocked, "Account temporarily locked"},
+	}
+
+	for _, c := range checks {
+		remaining, err := lockedFor(r.Context(), db, c.key)
+		if err != nil {
+			http.Error(w, "Failed to check login attempts: "+err.Error(), http.StatusInternalServerError)
+			return false
+		}
+
+		if remaining > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+			http.Error(w, c.message, c.status)
+			return false
+		}
+	}
+
+	return true
+}
+
+// recordLoginFailure counts a failed login against the client IP and the
+// account, locking either once it reaches its threshold.
+func recordLoginFailure(ctx context.Context, db *sql.DB, ip, userID string) error {
+	counters := []struct {
+		key       string
+		threshold int
+	}{
+		{ipLockoutKey(ip), loginIPMaxFailures},
+		{accountLockoutKey(userID), loginMaxFailures},
+	}
+
+	resetBefore := time.Now().Add(-loginFailureWindow)
+	for _, c := range counters {
+		failures, err := repositories.RecordLoginFailure(ctx, db, c.key, resetBefore)
+		if err != nil {
+			return err
+		}
+
+		if delay := lockoutDelay(failures, c.threshold); delay > 0 {
+			if err := repositories.LockLogin(ctx, db, c.key, time.Now().Add(delay)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// UnlockUser clears the failed login counter of an account: POST /users/{userID}/unlock
+func UnlockUser(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !requireScope(w, r, models.ScopeUsersAdmin) {
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[0] != "users" || parts[2] != "unlock" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		userID := parts[1]
+
+		cleared, err := repositories.ClearLoginFailures(r.Context(), db.(*sql.DB), accountLockoutKey(userID))
+		if err != nil {
+			http.Error(w, "Failed to unlock user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"id": userID, "unlocked": cleared})
+	}
+}
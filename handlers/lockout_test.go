@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var loginFailureColumns = []string{"key", "failures", "last_failure_at", "locked_until"}
+
+// expectNoLockout expects the login lockout lookups for the default
+// httptest client IP and userID, with neither of them locked.
+func expectNoLockout(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectQuery("SELECT key, failures").
+		WithArgs("ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows(loginFailureColumns))
+	mock.ExpectQuery("SELECT key, failures").
+		WithArgs("user:" + userID).
+		WillReturnRows(sqlmock.NewRows(loginFailureColumns))
+}
+
+func TestLockoutDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{4, 0},
+		{5, loginLockoutBase},
+		{6, 2 * loginLockoutBase},
+		{8, 8 * loginLockoutBase},
+		{100, loginLockoutMax},
+	}
+
+	for _, tt := range tests {
+		if got := lockoutDelay(tt.failures, 5); got != tt.want {
+			t.Errorf("lockoutDelay(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLogin_AccountLocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT key, failures").
+		WithArgs("ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows(loginFailureColumns))
+	mock.ExpectQuery("SELECT key, failures").
+		WithArgs("user:u1").
+		WillReturnRows(sqlmock.NewRows(loginFailureColumns).
+			AddRow("user:u1", 5, now, now.Add(90*time.Second)))
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"id":"u1","password":"guess"}`))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusLocked {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusLocked)
+	}
+	retry, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retry < 89 || retry > 90 {
+		t.Fatalf("Retry-After = %q, want ~90", rec.Header().Get("Retry-After"))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLogin_IPBackoff(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT key, failures").
+		WithArgs("ip:192.0.2.1").
+		WillReturnRows(sqlmock.NewRows(loginFailureColumns).
+			AddRow("ip:192.0.2.1", 21, now, now.Add(time.Minute)))
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"id":"u1","password":"guess"}`))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("expected Retry-After header")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLogin_FailureLocksAccountAtThreshold(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectNoLockout(mock, "u1")
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "not-a-bcrypt-hash", "member")...))
+
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:192.0.2.1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("user:u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(loginMaxFailures))
+	mock.ExpectExec("UPDATE login_failures").
+		WithArgs("user:u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"id":"u1","password":"guess"}`))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLogin_UnknownUserCountsFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectNoLockout(mock, "ghost")
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("ghost", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:192.0.2.1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("user:ghost", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"id":"ghost","password":"guess"}`))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUnlockUser_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/users/u2/unlock", nil)
+	rec := httptest.NewRecorder()
+
+	UnlockUser(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["unlocked"] != true {
+		t.Fatalf("unlocked = %v, want true", resp["unlocked"])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+// MeRouter serves the profile of the authenticated user:
+// GET /me, PATCH /me and DELETE /me
+func MeRouter(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			GetMe(db)(w, r)
+		case http.MethodPatch:
+			UpdateMe(db)(w, r)
+		case http.MethodDelete:
+			DeleteMe(db)(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// GetMe returns the profile of the user the token was issued to.
+func GetMe(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+// UpdateMe changes profile fields of the authenticated user. Fields left out
+// of the body are kept.
+func UpdateMe(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		// Keys act for services, not for the person owning the profile
+		if claims.APIKeyID != "" {
+			http.Error(w, "API keys cannot change the profile", http.StatusForbidden)
+			return
+		}
+
+		if !requireFullScope(w, r) {
+			return
+		}
+
+		var input models.UserProfileUpdate
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validateProfileUpdate(&input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := repositories.UpdateUserProfile(r.Context(), db.(*sql.DB), claims.Subject, input)
+		if err == repositories.ErrUserNotFound {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		GetMe(db)(w, r)
+	}
+}
+
+// MeFavouritesRouter serves /me/favourites[/{assetID}] as an alias of
+// /users/{userID}/favourites for the authenticated user.
+func MeFavouritesRouter(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		// The favourites handlers take the user from the path
+		alias := r.Clone(r.Context())
+		alias.URL.Path = "/users/" + claims.Subject + strings.TrimPrefix(r.URL.Path, "/me")
+		alias.URL.RawPath = ""
+
+		FavouritesRouter(db)(w, alias)
+	}
+}
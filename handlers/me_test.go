@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+func TestGetMe_ReturnsTokenSubject(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", "member")...))
+
+	req := withSubject(httptest.NewRequest(http.MethodGet, "/me", nil), "u2")
+	rec := httptest.NewRecorder()
+
+	MeRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["id"] != "u2" || resp["name"] != "Bob" {
+		t.Fatalf("unexpected profile: %v", resp)
+	}
+	if _, ok := resp["password_hash"]; ok {
+		t.Fatalf("password hash must not be returned: %v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetMe_RequiresAuthentication(t *testing.T) {
+	rec := httptest.NewRecorder()
+	MeRouter(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/me", nil))
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestUpdateMe_ChangesName(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u2", "Robert", nil, nil, "pt-BR", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Robert", "hash", "member")...))
+
+	req := withSubject(httptest.NewRequest(http.MethodPatch, "/me", strings.NewReader(`{"name":" Robert ","locale":"pt-BR"}`)), "u2")
+	rec := httptest.NewRecorder()
+
+	MeRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateMe_RejectsAPIKeys(t *testing.T) {
+	req := withAPIKey(httptest.NewRequest(http.MethodPatch, "/me", strings.NewReader(`{"name":"x"}`)), "u1", "member", "favourites:write")
+	rec := httptest.NewRecorder()
+
+	MeRouter(nil).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestMeFavourites_UsesTokenSubject(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", "member")...))
+	expectUserTx(mock, "u2", models.RoleMember)
+	mock.ExpectQuery("SELECT").
+		WithArgs("u2", "", "default", "u2", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "title", "data", "description"}).
+			AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{}`), nil))
+	mock.ExpectCommit()
+
+	req := withSubject(httptest.NewRequest(http.MethodGet, "/me/favourites", nil), "u2")
+	rec := httptest.NewRecorder()
+
+	MeFavouritesRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestMeFavourites_EnforcesScopes(t *testing.T) {
+	req := withAPIKey(httptest.NewRequest(http.MethodDelete, "/me/favourites/a1", nil), "u1", "member", "favourites:read")
+	rec := httptest.NewRecorder()
+
+	MeFavouritesRouter(nil).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
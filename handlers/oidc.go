@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/oidc"
+	"platform-go-challenge/repositories"
+)
+
+// oidcStateCookie binds an authorization request to the browser that
+// started it, so a callback cannot be replayed into another session.
+const oidcStateCookie = "oidc_state"
+
+// oidcLoginTTL bounds how long the user may take at the identity provider.
+var oidcLoginTTL = 10 * time.Minute
+
+// oidcProvider is nil until EnableOIDC is called, in which case the OIDC
+// endpoints respond 404.
+var oidcProvider *oidc.Provider
+
+// EnableOIDC turns on single sign-on through provider.
+func EnableOIDC(provider *oidc.Provider) {
+	oidcProvider = provider
+}
+
+// OIDCLogin starts an authorization code flow with PKCE at the configured
+// identity provider. Signed-in callers presenting their access token link
+// the provider account to their user instead of signing in with it.
+func OIDCLogin(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if oidcProvider == nil {
+			http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+			return
+		}
+
+		// Browsers ask for a cookie session with ?session=cookie, as with the
+		// "session" field of POST /login
+		sessionMode := r.URL.Query().Get("session")
+		if !validSessionMode(sessionMode) {
+			http.Error(w, "Invalid session mode", http.StatusBadRequest)
+			return
+		}
+
+		var linkUserID string
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			raw, ok := strings.CutPrefix(auth, "Bearer ")
+			if !ok {
+				http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := parseAccessToken(raw)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if claims.Act != nil {
+				http.Error(w, errImpersonating.Error(), http.StatusForbidden)
+				return
+			}
+			linkUserID = claims.Subject
+		}
+
+		state, err := generateOpaqueToken()
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+		nonce, err := generateOpaqueToken()
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+		verifier, err := oidc.GenerateVerifier()
+		if err != nil {
+			http.Error(w, "Failed to start login", http.StatusInternalServerError)
+			return
+		}
+
+		err = repositories.CreateOIDCLoginState(r.Context(), db.(*sql.DB), models.OIDCLoginState{
+			StateHash:    hashOpaqueToken(state),
+			Nonce:        nonce,
+			CodeVerifier: verifier,
+			LinkUserID:   linkUserID,
+			SessionMode:  sessionMode,
+			ExpiresAt:    time.Now().Add(oidcLoginTTL),
+		})
+		if err != nil {
+			http.Error(w, "Failed to start login: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    state,
+			Path:     "/auth/oidc",
+			MaxAge:   int(oidcLoginTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   cookieSecure,
+			SameSite: http.SameSiteLaxMode,
+		})
+
+		authURL := oidcProvider.AuthCodeURL(state, nonce, verifier)
+
+		// Single page apps fetch the URL and navigate themselves, which is
+		// the only way to send the access token when linking
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]string{"authorization_url": authURL})
+			return
+		}
+
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// OIDCCallback completes the flow started by OIDCLogin: it redeems the code,
+// verifies the ID token and signs in the linked user, provisioning a new
+// member on first login.
+func OIDCCallback(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if oidcProvider == nil {
+			http.Error(w, "OIDC login is not configured", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		if errCode := query.Get("error"); errCode != "" {
+			http.Error(w, "Sign-in failed: "+errCode, http.StatusUnauthorized)
+			return
+		}
+
+		state := query.Get("state")
+		cookie, err := r.Cookie(oidcStateCookie)
+		if state == "" || err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Path:     "/auth/oidc",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   cookieSecure,
+		})
+
+		login, err := repositories.ConsumeOIDCLoginState(r.Context(), db.(*sql.DB), hashOpaqueToken(state))
+		if err != nil {
+			http.Error(w, "Failed to complete login: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if login == nil {
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+
+		rawIDToken, err := oidcProvider.Exchange(r.Context(), query.Get("code"), login.CodeVerifier)
+		if err != nil {
+			http.Error(w, "Failed to redeem authorization code: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		idToken, err := oidcProvider.Verify(r.Context(), rawIDToken, login.Nonce)
+		if err != nil {
+			http.Error(w, "Invalid ID token: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		user, status, err := resolveOIDCUser(r, db.(*sql.DB), idToken, login.LinkUserID)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		if err := recordLogin(r, db.(*sql.DB), user.ID, models.LoginMethodOIDC); err != nil {
+			println("Error recording login:", err.Error())
+		}
+
+		resp, err := issueTokenPair(r.Context(), db.(*sql.DB), user, "", restrictScope("", user.Role), nil)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		writeTokenResponse(w, resp, login.SessionMode)
+	}
+}
+
+// resolveOIDCUser returns the local user for a verified ID token. Provider
+// accounts are only ever linked explicitly, never by matching emails, since
+// the provider's email cannot be trusted to belong to the local user.
+func resolveOIDCUser(r *http.Request, db *sql.DB, idToken *oidc.IDToken, linkUserID string) (*models.User, int, error) {
+	issuer := oidcProvider.Issuer()
+
+	identity, err := repositories.GetUserIdentity(r.Context(), db, issuer, idToken.Subject)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Failed to fetch identity: " + err.Error())
+	}
+
+	switch {
+	case identity != nil:
+		if linkUserID != "" && identity.UserID != linkUserID {
+			return nil, http.StatusConflict, errors.New("Identity is already linked to another user")
+		}
+		if err := repositories.TouchUserIdentity(r.Context(), db, issuer, idToken.Subject, idToken.Email); err != nil {
+			println("Error updating identity:", err.Error())
+		}
+		linkUserID = identity.UserID
+
+	case linkUserID != "":
+		err := repositories.CreateUserIdentity(r.Context(), db, models.UserIdentity{
+			Issuer:  issuer,
+			Subject: idToken.Subject,
+			UserID:  linkUserID,
+			Email:   idToken.Email,
+		})
+		if err != nil {
+			return nil, http.StatusInternalServerError, errors.New("Failed to link identity: " + err.Error())
+		}
+
+	default:
+		if !publicRegistration {
+			return nil, http.StatusForbidden, errors.New("Registration is by invitation only")
+		}
+
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, http.StatusInternalServerError, errors.New("Failed to create user")
+		}
+
+		user := &models.User{
+			ID:   "sso-" + hex.EncodeToString(id),
+			Name: oidcDisplayName(idToken),
+			Role: models.RoleMember,
+		}
+		err := repositories.CreateUserWithIdentity(r.Context(), db, *user, models.UserIdentity{
+			Issuer:  issuer,
+			Subject: idToken.Subject,
+			Email:   idToken.Email,
+		})
+		if err != nil {
+			return nil, http.StatusInternalServerError, errors.New("Failed to create user: " + err.Error())
+		}
+
+		return user, http.StatusOK, nil
+	}
+
+	user, err := repositories.GetUserByID(r.Context(), db, linkUserID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Failed to fetch user: " + err.Error())
+	}
+	if user == nil {
+		return nil, http.StatusUnauthorized, errors.New("User not found")
+	}
+	if user.DisabledAt != nil {
+		return nil, http.StatusForbidden, errors.New("Account is disabled")
+	}
+	if user.DeletedAt != nil {
+		return nil, http.StatusForbidden, errors.New("Account is scheduled for deletion")
+	}
+
+	return user, http.StatusOK, nil
+}
+
+// oidcDisplayName picks the most readable name the provider shared.
+func oidcDisplayName(idToken *oidc.IDToken) string {
+	for _, name := range []string{idToken.Name, idToken.PreferredUsername, idToken.Email} {
+		if name != "" {
+			return name
+		}
+	}
+	return idToken.Subject
+}
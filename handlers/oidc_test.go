@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/oidc"
+	"platform-go-challenge/oidc/oidctest"
+)
+
+var identityColumns = []string{"issuer", "subject", "user_id", "email", "created_at", "last_login_at"}
+
+// useOIDC enables single sign-on through idp for the test.
+func useOIDC(t *testing.T, idp *oidctest.Server) {
+	t.Helper()
+
+	provider, err := oidc.Discover(context.Background(), idp.Config("http://example.com/auth/oidc/callback"))
+	if err != nil {
+		t.Fatalf("Discover error: %v", err)
+	}
+
+	orig := oidcProvider
+	t.Cleanup(func() { oidcProvider = orig })
+	EnableOIDC(provider)
+}
+
+// capture is a sqlmock argument matching anything and remembering it.
+type capture struct{ value *string }
+
+func (c capture) Match(v driver.Value) bool {
+	*c.value, _ = v.(string)
+	return true
+}
+
+// startOIDCLogin runs OIDCLogin, lets the stub provider approve it and
+// returns the callback request the browser would make. sessionMode is passed
+// as the session query parameter when set.
+func startOIDCLogin(t *testing.T, db *sql.DB, mock sqlmock.Sqlmock, idp *oidctest.Server, linkUserID, sessionMode string) *http.Request {
+	t.Helper()
+
+	var nonce, verifier string
+	mock.ExpectExec("DELETE FROM oidc_login_states").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO oidc_login_states").
+		WithArgs(sqlmock.AnyArg(), capture{&nonce}, capture{&verifier}, linkUserID, sessionMode, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	target := "/auth/oidc/login"
+	if sessionMode != "" {
+		target += "?session=" + sessionMode
+	}
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if linkUserID != "" {
+		token, err := IssueToken(&models.User{ID: linkUserID, Role: models.RoleMember})
+		if err != nil {
+			t.Fatalf("IssueToken error: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+
+	OIDCLogin(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want %d: %s", rec.Code, http.StatusFound, rec.Body.String())
+	}
+
+	callback, err := idp.Authorize(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Authorize error: %v", err)
+	}
+
+	mock.ExpectQuery("DELETE FROM oidc_login_states").
+		WithArgs(hashOpaqueToken(callback.Query().Get("state"))).
+		WillReturnRows(sqlmock.NewRows([]string{"state_hash", "nonce", "code_verifier", "link_user_id", "session_mode", "expires_at"}).
+			AddRow("hash", nonce, verifier, linkUserID, sessionMode, time.Now().Add(time.Minute)))
+
+	callbackReq := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	for _, cookie := range rec.Result().Cookies() {
+		callbackReq.AddCookie(cookie)
+	}
+	return callbackReq
+}
+
+func TestOIDCLogin_ProvisionsUserOnFirstLogin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	idp := oidctest.NewServer()
+	defer idp.Close()
+	idp.SetUser(oidctest.User{Subject: "abc", Email: "carol@example.com", Name: "Carol"})
+	useOIDC(t, idp)
+
+	req := startOIDCLogin(t, db, mock, idp, "", "")
+
+	mock.ExpectQuery("SELECT issuer, subject, user_id").
+		WithArgs(idp.URL, "abc", "default").
+		WillReturnRows(sqlmock.NewRows(identityColumns))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs(sqlmock.AnyArg(), "Carol", "", models.RoleMember, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_identities").
+		WithArgs(idp.URL, "abc", sqlmock.AnyArg(), "carol@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE users SET last_login_at").
+		WithArgs(sqlmock.AnyArg(), models.LoginMethodOIDC, sqlmock.AnyArg(), sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "favourites:read favourites:write", sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	OIDCCallback(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	claims, err := parseAccessToken(resp.Token)
+	if err != nil {
+		t.Fatalf("issued token does not verify: %v", err)
+	}
+	if claims.Role != models.RoleMember || len(claims.Subject) != len("sso-")+16 {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOIDCLogin_SignsInLinkedUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	idp := oidctest.NewServer()
+	defer idp.Close()
+	useOIDC(t, idp)
+
+	req := startOIDCLogin(t, db, mock, idp, "", "")
+
+	mock.ExpectQuery("SELECT issuer, subject, user_id").
+		WithArgs(idp.URL, "subject-1", "default").
+		WillReturnRows(sqlmock.NewRows(identityColumns).
+			AddRow(idp.URL, "subject-1", "u1", "alice@example.com", time.Now(), nil))
+	mock.ExpectExec("UPDATE user_identities").
+		WithArgs(idp.URL, "subject-1", "alice@example.com", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", models.RoleAdmin)...))
+	expectRecordLogin(mock, "u1")
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	OIDCCallback(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOIDCLogin_CookieSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	idp := oidctest.NewServer()
+	defer idp.Close()
+	useOIDC(t, idp)
+
+	req := startOIDCLogin(t, db, mock, idp, "", sessionModeCookie)
+
+	mock.ExpectQuery("SELECT issuer, subject, user_id").
+		WithArgs(idp.URL, "subject-1", "default").
+		WillReturnRows(sqlmock.NewRows(identityColumns).
+			AddRow(idp.URL, "subject-1", "u1", "alice@example.com", time.Now(), nil))
+	mock.ExpectExec("UPDATE user_identities").
+		WithArgs(idp.URL, "subject-1", "alice@example.com", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", models.RoleMember)...))
+	expectRecordLogin(mock, "u1")
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec := httptest.NewRecorder()
+	OIDCCallback(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if _, ok := resp["token"]; ok {
+		t.Fatalf("tokens must not be returned in the body: %v", resp)
+	}
+	cookies := responseCookies(rec)
+	if cookies[sessionCookie] == nil || cookies[refreshCookie] == nil || cookies[csrfCookie] == nil {
+		t.Fatalf("expected session, refresh and CSRF cookies, got %v", cookies)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOIDCLogin_InvalidSessionMode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	idp := oidctest.NewServer()
+	defer idp.Close()
+	useOIDC(t, idp)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/login?session=local", nil)
+	rec := httptest.NewRecorder()
+
+	OIDCLogin(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOIDCLogin_LinkingIdentityOfAnotherUserConflicts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	idp := oidctest.NewServer()
+	defer idp.Close()
+	useOIDC(t, idp)
+
+	req := startOIDCLogin(t, db, mock, idp, "u2", "")
+
+	mock.ExpectQuery("SELECT issuer, subject, user_id").
+		WithArgs(idp.URL, "subject-1", "default").
+		WillReturnRows(sqlmock.NewRows(identityColumns).
+			AddRow(idp.URL, "subject-1", "u1", "alice@example.com", time.Now(), nil))
+
+	rec := httptest.NewRecorder()
+	OIDCCallback(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("callback status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOIDCCallback_RejectsStateFromAnotherBrowser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	idp := oidctest.NewServer()
+	defer idp.Close()
+	useOIDC(t, idp)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?code=c&state=victim", nil)
+	req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: "attacker"})
+	rec := httptest.NewRecorder()
+
+	OIDCCallback(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOIDCLogin_NotConfigured(t *testing.T) {
+	orig := oidcProvider
+	oidcProvider = nil
+	defer func() { oidcProvider = orig }()
+
+	rec := httptest.NewRecorder()
+	OIDCLogin(nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
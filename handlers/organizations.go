@@ -0,0 +1,604 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+// OrganizationsRouter manages organizations and their members:
+// GET /orgs, POST /orgs,
+// GET/PATCH/DELETE /orgs/{orgID},
+// GET/POST /orgs/{orgID}/members and
+// PATCH/DELETE /orgs/{orgID}/members/{userID}
+func OrganizationsRouter(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+		if parts[0] != "orgs" || len(parts) > 4 || (len(parts) > 2 && parts[2] != "members") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !safeMethod(r.Method) && !requireFullScope(w, r) {
+			return
+		}
+
+		switch len(parts) {
+		case 1:
+			switch r.Method {
+			case http.MethodGet:
+				ListOrganizations(db)(w, r)
+				return
+			case http.MethodPost:
+				CreateOrganization(db)(w, r)
+				return
+			}
+
+		case 2:
+			switch r.Method {
+			case http.MethodGet:
+				GetOrganization(db, parts[1])(w, r)
+				return
+			case http.MethodPatch:
+				UpdateOrganization(db, parts[1])(w, r)
+				return
+			case http.MethodDelete:
+				DeleteOrganization(db, parts[1])(w, r)
+				return
+			}
+
+		case 3:
+			switch r.Method {
+			case http.MethodGet:
+				ListOrganizationMembers(db, parts[1])(w, r)
+				return
+			case http.MethodPost:
+				AddOrganizationMember(db, parts[1])(w, r)
+				return
+			}
+
+		case 4:
+			switch r.Method {
+			case http.MethodPatch:
+				UpdateOrganizationMember(db, parts[1], parts[3])(w, r)
+				return
+			case http.MethodDelete:
+				RemoveOrganizationMember(db, parts[1], parts[3])(w, r)
+				return
+			}
+		}
+
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// orgAdmin reports whether the caller administers every organization, as
+// admins holding the users:admin scope do.
+func orgAdmin(r *http.Request) bool {
+	if !authEnabled {
+		return true
+	}
+
+	claims, ok := claimsFromContext(r.Context())
+	return ok && claims.Role == models.RoleAdmin && hasScope(r, models.ScopeUsersAdmin)
+}
+
+// orgOwner reports whether the caller may grant or take away the owner role.
+func orgOwner(r *http.Request, member *models.OrganizationMember) bool {
+	return orgAdmin(r) || (member != nil && member.Role == models.OrgRoleOwner)
+}
+
+// requireOrgMember returns the caller's membership of orgID. It writes an
+// error and returns false unless the caller belongs to the organization and,
+// with manage, is one of its owners or admins. Organizations the caller does
+// not belong to are reported as not found. Admins of every organization pass
+// with a nil membership when they are not members themselves.
+func requireOrgMember(w http.ResponseWriter, r *http.Request, db *sql.DB, orgID string, manage bool) (*models.OrganizationMember, bool) {
+	var member *models.OrganizationMember
+
+	if claims, ok := claimsFromContext(r.Context()); ok && claims.Subject != "" {
+		var err error
+		member, err = repositories.GetOrganizationMember(r.Context(), db, orgID, claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to fetch membership: "+err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+	}
+
+	if member == nil && orgAdmin(r) {
+		org, err := repositories.GetOrganization(r.Context(), db, orgID)
+		if err != nil {
+			http.Error(w, "Failed to fetch organization: "+err.Error(), http.StatusInternalServerError)
+			return nil, false
+		}
+		if org == nil {
+			http.Error(w, "organization not found", http.StatusNotFound)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if member == nil {
+		http.Error(w, "organization not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	if manage && !member.CanManage() && !orgAdmin(r) {
+		http.Error(w, "Organization owner or admin role required", http.StatusForbidden)
+		return nil, false
+	}
+
+	return member, true
+}
+
+// orgScope returns the organization to limit listings to: the org query
+// parameter, or else the active organization of the token, or "" for none.
+// Callers must belong to the organization unless they administer every
+// organization; the token's organization was checked when it was issued.
+func orgScope(w http.ResponseWriter, r *http.Request, db *sql.DB) (string, bool) {
+	claims, ok := claimsFromContext(r.Context())
+
+	orgID := r.URL.Query().Get("org")
+	if orgID == "" {
+		if ok {
+			return claims.OrgID, true
+		}
+		return "", true
+	}
+
+	if (ok && orgID == claims.OrgID) || orgAdmin(r) {
+		return orgID, true
+	}
+
+	if !ok || claims.Subject == "" {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return "", false
+	}
+
+	member, err := repositories.GetOrganizationMember(r.Context(), db, orgID, claims.Subject)
+	if err != nil {
+		http.Error(w, "Failed to fetch membership: "+err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+	if member == nil {
+		http.Error(w, "Not a member of the organization", http.StatusForbidden)
+		return "", false
+	}
+
+	return orgID, true
+}
+
+// ListOrganizations returns the organizations of the caller, or every
+// organization for admins passing ?all=true.
+func ListOrganizations(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		userID := claims.Subject
+		if r.URL.Query().Get("all") == "true" {
+			if !orgAdmin(r) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			userID = ""
+		}
+
+		orgs, err := repositories.ListOrganizations(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch organizations: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(orgs)
+	}
+}
+
+// CreateOrganization creates an organization owned by the caller.
+func CreateOrganization(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		// Keys act for services, which cannot own organizations
+		if claims.APIKeyID != "" {
+			http.Error(w, "API keys cannot create organizations", http.StatusForbidden)
+			return
+		}
+
+		var input struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		input.Name = strings.TrimSpace(input.Name)
+		if !models.ValidOrgID(input.ID) {
+			http.Error(w, "ID must be lowercase letters, digits and dashes", http.StatusBadRequest)
+			return
+		}
+		if input.Name == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return
+		}
+
+		org, err := repositories.CreateOrganization(r.Context(), db.(*sql.DB), models.Organization{
+			ID:   input.ID,
+			Name: input.Name,
+		}, claims.Subject)
+		if errors.Is(err, repositories.ErrOrganizationExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to create organization: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(org)
+	}
+}
+
+func GetOrganization(db DB, orgID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		member, ok := requireOrgMember(w, r, db.(*sql.DB), orgID, false)
+		if !ok {
+			return
+		}
+
+		org, err := repositories.GetOrganization(r.Context(), db.(*sql.DB), orgID)
+		if err != nil {
+			http.Error(w, "Failed to fetch organization: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if org == nil {
+			http.Error(w, "organization not found", http.StatusNotFound)
+			return
+		}
+		if member != nil {
+			org.Role = member.Role
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(org)
+	}
+}
+
+// UpdateOrganization renames an organization. Owners and admins only.
+func UpdateOrganization(db DB, orgID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireOrgMember(w, r, db.(*sql.DB), orgID, true); !ok {
+			return
+		}
+
+		var input struct {
+			Name string `json:"name"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		input.Name = strings.TrimSpace(input.Name)
+		if input.Name == "" {
+			http.Error(w, "Name is required", http.StatusBadRequest)
+			return
+		}
+
+		org, err := repositories.UpdateOrganization(r.Context(), db.(*sql.DB), orgID, input.Name)
+		if errors.Is(err, repositories.ErrOrganizationNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update organization: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(org)
+	}
+}
+
+// DeleteOrganization removes an organization along with its assets. Owners
+// only.
+func DeleteOrganization(db DB, orgID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		member, ok := requireOrgMember(w, r, db.(*sql.DB), orgID, true)
+		if !ok {
+			return
+		}
+		if !orgOwner(r, member) {
+			http.Error(w, "Organization owner role required", http.StatusForbidden)
+			return
+		}
+
+		err := repositories.DeleteOrganization(r.Context(), db.(*sql.DB), orgID)
+		if errors.Is(err, repositories.ErrOrganizationNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to delete organization: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func ListOrganizationMembers(db DB, orgID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := requireOrgMember(w, r, db.(*sql.DB), orgID, false); !ok {
+			return
+		}
+
+		members, err := repositories.ListOrganizationMembers(r.Context(), db.(*sql.DB), orgID)
+		if err != nil {
+			http.Error(w, "Failed to fetch members: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(members)
+	}
+}
+
+// AddOrganizationMember adds a user to an organization, as a member unless
+// another role is given. Only owners can add owners.
+func AddOrganizationMember(db DB, orgID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := requireOrgMember(w, r, db.(*sql.DB), orgID, true)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			UserID string `json:"user_id"`
+			Role   string `json:"role"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if input.UserID == "" {
+			http.Error(w, "user_id is required", http.StatusBadRequest)
+			return
+		}
+		if input.Role == "" {
+			input.Role = models.OrgRoleMember
+		}
+		if !models.ValidOrgRole(input.Role) {
+			http.Error(w, "Role must be owner, admin or member", http.StatusBadRequest)
+			return
+		}
+		if input.Role == models.OrgRoleOwner && !orgOwner(r, caller) {
+			http.Error(w, "Organization owner role required", http.StatusForbidden)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), input.UserID)
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil || user.DeletedAt != nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		member, err := repositories.AddOrganizationMember(r.Context(), db.(*sql.DB), models.OrganizationMember{
+			OrgID:  orgID,
+			UserID: user.ID,
+			Role:   input.Role,
+		})
+		if errors.Is(err, repositories.ErrOrganizationMemberExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to add member: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		member.Name = user.Name
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(member)
+	}
+}
+
+// UpdateOrganizationMember changes the role of a member. Only owners can
+// promote to or demote from owner, and the last owner cannot step down.
+func UpdateOrganizationMember(db DB, orgID, userID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caller, ok := requireOrgMember(w, r, db.(*sql.DB), orgID, true)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Role string `json:"role"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if !models.ValidOrgRole(input.Role) {
+			http.Error(w, "Role must be owner, admin or member", http.StatusBadRequest)
+			return
+		}
+
+		target, err := repositories.GetOrganizationMember(r.Context(), db.(*sql.DB), orgID, userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch membership: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if target == nil {
+			http.Error(w, repositories.ErrOrganizationMemberNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		if (input.Role == models.OrgRoleOwner || target.Role == models.OrgRoleOwner) && !orgOwner(r, caller) {
+			http.Error(w, "Organization owner role required", http.StatusForbidden)
+			return
+		}
+
+		member, err := repositories.UpdateOrganizationMemberRole(r.Context(), db.(*sql.DB), orgID, userID, input.Role)
+		if errors.Is(err, repositories.ErrOrganizationMemberNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repositories.ErrLastOrganizationOwner) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update member: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(member)
+	}
+}
+
+// RemoveOrganizationMember removes a user from an organization. Members may
+// always leave; removing others takes an owner or admin, and removing an
+// owner takes an owner. The last owner cannot leave.
+func RemoveOrganizationMember(db DB, orgID, userID string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := claimsFromContext(r.Context())
+		leaving := claims != nil && claims.Subject == userID
+
+		caller, ok := requireOrgMember(w, r, db.(*sql.DB), orgID, !leaving)
+		if !ok {
+			return
+		}
+
+		if !leaving {
+			target, err := repositories.GetOrganizationMember(r.Context(), db.(*sql.DB), orgID, userID)
+			if err != nil {
+				http.Error(w, "Failed to fetch membership: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if target != nil && target.Role == models.OrgRoleOwner && !orgOwner(r, caller) {
+				http.Error(w, "Organization owner role required", http.StatusForbidden)
+				return
+			}
+		}
+
+		err := repositories.RemoveOrganizationMember(r.Context(), db.(*sql.DB), orgID, userID)
+		if errors.Is(err, repositories.ErrOrganizationMemberNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, repositories.ErrLastOrganizationOwner) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to remove member: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SwitchOrganization starts a new login acting in another organization:
+// POST /me/org
+// The new token pair carries the organization and the caller's role in it;
+// an empty org_id switches back to acting outside any organization.
+func SwitchOrganization(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		// Neither keys nor impersonation tokens come with refresh tokens
+		if claims.APIKeyID != "" {
+			http.Error(w, "API keys cannot switch organization", http.StatusForbidden)
+			return
+		}
+		if rejectImpersonation(w, r) {
+			return
+		}
+
+		var input struct {
+			OrgID string `json:"org_id"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil || user.DisabledAt != nil || user.DeletedAt != nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		var member *models.OrganizationMember
+		if input.OrgID != "" {
+			member, err = repositories.GetOrganizationMember(r.Context(), db.(*sql.DB), input.OrgID, user.ID)
+			if err != nil {
+				http.Error(w, "Failed to fetch membership: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if member == nil {
+				http.Error(w, "Not a member of the organization", http.StatusForbidden)
+				return
+			}
+		}
+
+		sessionMode := ""
+		if _, err := r.Cookie(sessionCookie); err == nil {
+			sessionMode = sessionModeCookie
+		}
+
+		resp, err := issueTokenPair(r.Context(), db.(*sql.DB), user, "", restrictScope(claims.Scope, user.Role), member)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		writeTokenResponse(w, resp, sessionMode)
+	}
+}
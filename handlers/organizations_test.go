@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+var orgMemberColumns = []string{"org_id", "user_id", "role", "created_at"}
+
+// withOrg adds an active organization to the claims of req.
+func withOrg(req *http.Request, orgID, orgRole string) *http.Request {
+	claims, _ := claimsFromContext(req.Context())
+	scoped := *claims
+	scoped.OrgID, scoped.OrgRole = orgID, orgRole
+	return req.WithContext(context.WithValue(req.Context(), claimsContextKey, &scoped))
+}
+
+// expectMembership expects the lookup of userID in orgID, returning role or
+// no row when role is empty.
+func expectMembership(mock sqlmock.Sqlmock, orgID, userID, role string) {
+	rows := sqlmock.NewRows(orgMemberColumns)
+	if role != "" {
+		rows.AddRow(orgID, userID, role, time.Now())
+	}
+	mock.ExpectQuery("FROM organization_members").
+		WithArgs(orgID, userID, "default").
+		WillReturnRows(rows)
+}
+
+func TestCreateOrganization_CallerBecomesOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO organizations").
+		WithArgs("acme", "Acme", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}).
+			AddRow("acme", "Acme", now, now))
+	mock.ExpectExec("INSERT INTO organization_members").
+		WithArgs("acme", "u2", models.OrgRoleOwner, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	body := `{"id":"acme","name":" Acme "}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/orgs", strings.NewReader(body)), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	OrganizationsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var org models.Organization
+	if err := json.NewDecoder(rec.Body).Decode(&org); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if org.ID != "acme" || org.Role != models.OrgRoleOwner {
+		t.Fatalf("unexpected organization: %+v", org)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateOrganization_InvalidID(t *testing.T) {
+	body := `{"id":"Acme Corp","name":"Acme"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/orgs", strings.NewReader(body)), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	OrganizationsRouter(nil).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetOrganization_HiddenFromNonMembers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectMembership(mock, "acme", "u3", "")
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/orgs/acme", nil), "u3", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	OrganizationsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNotFound, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAddOrganizationMember_RequiresManager(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectMembership(mock, "acme", "u2", models.OrgRoleMember)
+
+	body := `{"user_id":"u3"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/orgs/acme/members", strings.NewReader(body)), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	OrganizationsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAddOrganizationMember_AdminCannotAddOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectMembership(mock, "acme", "u2", models.OrgRoleAdmin)
+
+	body := `{"user_id":"u3","role":"owner"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/orgs/acme/members", strings.NewReader(body)), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	OrganizationsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAddOrganizationMember_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectMembership(mock, "acme", "u1", models.OrgRoleOwner)
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u3", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u3", "Carol", "hash", models.RoleMember)...))
+	mock.ExpectQuery("INSERT INTO organization_members").
+		WithArgs("acme", "u3", models.OrgRoleAdmin, "default").
+		WillReturnRows(sqlmock.NewRows(orgMemberColumns).
+			AddRow("acme", "u3", models.OrgRoleAdmin, time.Now()))
+
+	body := `{"user_id":"u3","role":"admin"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/orgs/acme/members", strings.NewReader(body)), "u1", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	OrganizationsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	var member models.OrganizationMember
+	if err := json.NewDecoder(rec.Body).Decode(&member); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if member.UserID != "u3" || member.Name != "Carol" || member.Role != models.OrgRoleAdmin {
+		t.Fatalf("unexpected member: %+v", member)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRemoveOrganizationMember_LastOwnerCannotLeave(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectMembership(mock, "acme", "u1", models.OrgRoleOwner)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id").
+		WithArgs("acme", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1"))
+	mock.ExpectRollback()
+
+	req := withClaims(httptest.NewRequest(http.MethodDelete, "/orgs/acme/members/u1", nil), "u1", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	OrganizationsRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSwitchOrganization_IssuesTokenWithOrg(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", models.RoleMember)...))
+	expectMembership(mock, "acme", "u2", models.OrgRoleMember)
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u2", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "acme", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"org_id":"acme"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/me/org", strings.NewReader(body)), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	SwitchOrganization(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	claims, err := parseAccessToken(resp.Token)
+	if err != nil {
+		t.Fatalf("issued token does not verify: %v", err)
+	}
+	if claims.OrgID != "acme" || claims.OrgRole != models.OrgRoleMember || resp.OrgID != "acme" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSwitchOrganization_NotAMember(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", models.RoleMember)...))
+	expectMembership(mock, "globex", "u2", "")
+
+	body := `{"org_id":"globex"}`
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/me/org", strings.NewReader(body)), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	SwitchOrganization(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRefreshToken_KeepsOrganization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE refresh_tokens").
+		WithArgs(hashOpaqueToken("old-token"), "default").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+			AddRow(hashOpaqueToken("old-token"), "u2", "fam1", "", now.Add(time.Hour), now, now, "acme"))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", models.RoleMember)...))
+	expectMembership(mock, "acme", "u2", models.OrgRoleAdmin)
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u2", "fam1", sqlmock.AnyArg(), sqlmock.AnyArg(), "acme", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"refresh_token":"old-token"}`
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	RefreshToken(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	claims, err := parseAccessToken(resp.Token)
+	if err != nil {
+		t.Fatalf("issued token does not verify: %v", err)
+	}
+	if claims.OrgID != "acme" || claims.OrgRole != models.OrgRoleAdmin {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetAllAssets_ScopedToActiveOrganization(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectUserTx(mock, "u2", models.RoleMember)
+	mock.ExpectQuery("FROM assets").
+		WithArgs("acme", "default", "u2", false).
+		WillReturnRows(sqlmock.NewRows(assetColumns).
+			AddRow("a1", models.AssetChart, "Sales", json.RawMessage(`{}`), time.Now(), "acme", "u1", models.VisibilityOrg))
+	mock.ExpectCommit()
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/assets", nil), "u2", models.RoleMember)
+	req = withOrg(req, "acme", models.OrgRoleMember)
+	rec := httptest.NewRecorder()
+
+	GetAllAssets(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetUserFavourites_OtherOrganizationForbidden(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", models.RoleMember)...))
+	expectMembership(mock, "globex", "u2", "")
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/users/u2/favourites?org=globex", nil), "u2", models.RoleMember)
+	rec := httptest.NewRecorder()
+
+	GetUserFavourites(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"platform-go-challenge/mail"
+	"platform-go-challenge/passwords"
+	"platform-go-challenge/repositories"
+)
+
+var mailer mail.Mailer
+
+var passwordResetTTL = time.Hour
+
+// passwordResetURL is the page users are sent to, with ?token= appended.
+var passwordResetURL = "http://localhost:8080/password/reset"
+
+// passwordResetResendInterval is the minimum time between two password reset
+// emails to the same user.
+var passwordResetResendInterval = time.Minute
+
+var passwordHasher *passwords.Hasher
+
+func init() {
+	// Password hashing, e.g. PASSWORD_HASH_ALGORITHM=bcrypt BCRYPT_COST=12
+	params := passwords.DefaultParams()
+	if algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm != "" {
+		params.Algorithm = algorithm
+	}
+	if n, err := strconv.Atoi(os.Getenv("BCRYPT_COST")); err == nil {
+		params.BcryptCost = n
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY_KIB"), 10, 32); err == nil {
+		params.Argon2Memory = uint32(n)
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_TIME"), 10, 32); err == nil {
+		params.Argon2Time = uint32(n)
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_THREADS"), 10, 8); err == nil {
+		params.Argon2Threads = uint8(n)
+	}
+	hasher, err := passwords.NewHasher(params)
+	if err != nil {
+		log.Fatalf("invalid password hashing configuration: %v", err)
+	}
+	passwordHasher = hasher
+
+	// Outgoing mail is written to a local outbox directory
+	outboxDir := os.Getenv("MAIL_OUTBOX_DIR")
+	if outboxDir == "" {
+		outboxDir = "outbox"
+	}
+	mailer = &mail.FileOutbox{Dir: outboxDir}
+
+	if resetURL := os.Getenv("PASSWORD_RESET_URL"); resetURL != "" {
+		passwordResetURL = resetURL
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL")); err == nil && ttl > 0 {
+		passwordResetTTL = ttl
+	}
+	if d, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_RESEND_INTERVAL")); err == nil && d >= 0 {
+		passwordResetResendInterval = d
+	}
+}
+
+// ForgotPassword emails a single-use reset token: POST /password/forgot
+// It always responds 202 so callers cannot tell which accounts exist.
+func ForgotPassword(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var input struct {
+			ID string `json:"id"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if input.ID == "" {
+			http.Error(w, "ID required", http.StatusBadRequest)
+			return
+		}
+
+		if err := sendPasswordReset(r, db.(*sql.DB), input.ID); err != nil {
+			println("Error sending password reset:", err.Error())
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "If the account exists, a password reset link has been sent",
+		})
+	}
+}
+
+// sendPasswordReset emails userID a reset token, when the user has a
+// verified email address and was not sent one less than
+// passwordResetResendInterval ago.
+func sendPasswordReset(r *http.Request, db *sql.DB, userID string) error {
+	user, err := repositories.GetUserByID(r.Context(), db, userID)
+	if err != nil || user == nil {
+		return err
+	}
+
+	// Without a confirmed address there is nobody to send the token to
+	if user.Email == "" || user.EmailVerifiedAt == nil {
+		return nil
+	}
+
+	ok, err := repositories.MarkPasswordResetSent(r.Context(), db, user.ID, time.Now().Add(-passwordResetResendInterval))
+	if err != nil || !ok {
+		return err
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(passwordResetTTL)
+	if err := repositories.CreatePasswordResetToken(r.Context(), db, hashOpaqueToken(token), user.ID, expiresAt); err != nil {
+		return err
+	}
+
+	link := passwordResetURL + "?token=" + url.QueryEscape(token)
+	return mailer.Send(r.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nReset token: %s\n\nIf you did not ask for this, you can ignore this email.\n",
+			user.Name, passwordResetTTL, link, token,
+		),
+	})
+}
+
+// ResetPassword sets a new password using a reset token: POST /password/reset
+func ResetPassword(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var input struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if input.Token == "" || input.Password == "" {
+			http.Error(w, "Token and password required", http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Password) < minPasswordLength {
+			http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+			return
+		}
+
+		userID, err := repositories.ConsumePasswordResetToken(r.Context(), db.(*sql.DB), hashOpaqueToken(input.Token))
+		if err != nil {
+			http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if userID == "" {
+			http.Error(w, "Invalid or expired reset token", http.StatusBadRequest)
+			return
+		}
+
+		if err := setPassword(r, db.(*sql.DB), userID, input.Password); err != nil {
+			http.Error(w, "Failed to reset password: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// The owner of the mailbox proved who they are, so lift any lockout
+		if _, err := repositories.ClearLoginFailures(r.Context(), db.(*sql.DB), accountLockoutKey(userID)); err != nil {
+			println("Error clearing login failures:", err.Error())
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ChangePassword changes the caller's password: POST /me/password
+// Every existing session is revoked and a fresh token pair is returned.
+func ChangePassword(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if rejectImpersonation(w, r) {
+			return
+		}
+
+		if !requireFullScope(w, r) {
+			return
+		}
+
+		var input struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if input.CurrentPassword == "" || input.NewPassword == "" {
+			http.Error(w, "Current and new password required", http.StatusBadRequest)
+			return
+		}
+
+		if len(input.NewPassword) < minPasswordLength {
+			http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		// The new session keeps the scopes of the token that changed the password
+		scope := restrictScope(claims.Scope, user.Role)
+		if scope == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if match, _, _ := passwordHasher.Verify(input.CurrentPassword, user.PasswordHash); !match {
+			http.Error(w, "Current password is incorrect", http.StatusForbidden)
+			return
+		}
+
+		if err := setPassword(r, db.(*sql.DB), user.ID, input.NewPassword); err != nil {
+			http.Error(w, "Failed to change password: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := issueTokenPair(r.Context(), db.(*sql.DB), user, "", scope, nil)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// setPassword stores a new password for userID and revokes everything that
+// was issued with the old one: sessions, access tokens and reset tokens.
+func setPassword(r *http.Request, db *sql.DB, userID, password string) error {
+	hashedPassword, err := passwordHasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	if err := repositories.UpdateUserPassword(r.Context(), db, userID, hashedPassword); err != nil {
+		return err
+	}
+
+	if err := revokeAllUserTokens(r.Context(), db, userID); err != nil {
+		return err
+	}
+
+	return repositories.InvalidatePasswordResetTokens(r.Context(), db, userID)
+}
@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"golang.org/x/crypto/bcrypt"
+
+	"platform-go-challenge/mail"
+	"platform-go-challenge/models"
+)
+
+// recordingMailer keeps sent messages in memory.
+type recordingMailer struct {
+	messages []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// useRecordingMailer replaces the mailer for the duration of the test.
+func useRecordingMailer(t *testing.T) *recordingMailer {
+	t.Helper()
+
+	orig := mailer
+	t.Cleanup(func() { mailer = orig })
+
+	m := &recordingMailer{}
+	mailer = m
+	return m
+}
+
+// expectSetPassword expects the queries run by setPassword for userID.
+func expectSetPassword(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectExec("UPDATE users").
+		WithArgs(userID, sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_token_revocations").
+		WithArgs(userID, sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens").
+		WithArgs(userID, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE password_reset_tokens").
+		WithArgs(userID, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestForgotPassword_UnknownUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	outbox := useRecordingMailer(t)
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("ghost", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"id":"ghost"}`))
+	rec := httptest.NewRecorder()
+
+	ForgotPassword(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if len(outbox.messages) != 0 {
+		t.Fatalf("expected no mail, got %d", len(outbox.messages))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestForgotPassword_SendsToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	outbox := useRecordingMailer(t)
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(verifiedUserRow("u1", "Alice", "alice@example.com")...))
+	mock.ExpectExec("SET password_reset_sent_at").
+		WithArgs("u1", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO password_reset_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"id":"u1"}`))
+	rec := httptest.NewRecorder()
+
+	ForgotPassword(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if len(outbox.messages) != 1 {
+		t.Fatalf("expected 1 mail, got %d", len(outbox.messages))
+	}
+	if msg := outbox.messages[0]; msg.To != "alice@example.com" || !strings.Contains(msg.Body, passwordResetURL+"?token=") {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestForgotPassword_WithoutVerifiedEmail(t *testing.T) {
+	for name, row := range map[string][]driver.Value{
+		"no email":         userRow("u1", "Alice", "hash", "member"),
+		"unverified email": unverifiedUserRow("u1", "Alice", "alice@example.com"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New error: %v", err)
+			}
+			defer db.Close()
+
+			outbox := useRecordingMailer(t)
+
+			mock.ExpectQuery("SELECT id, name, password_hash").
+				WithArgs("u1", "default").
+				WillReturnRows(sqlmock.NewRows(userColumns).AddRow(row...))
+
+			req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"id":"u1"}`))
+			rec := httptest.NewRecorder()
+
+			ForgotPassword(db).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+			}
+			if len(outbox.messages) != 0 {
+				t.Fatalf("expected no mail, got %+v", outbox.messages)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestForgotPassword_RateLimited(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	outbox := useRecordingMailer(t)
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(verifiedUserRow("u1", "Alice", "alice@example.com")...))
+	// A reset email went out less than passwordResetResendInterval ago
+	mock.ExpectExec("SET password_reset_sent_at").
+		WithArgs("u1", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"id":"u1"}`))
+	rec := httptest.NewRecorder()
+
+	ForgotPassword(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if len(outbox.messages) != 0 {
+		t.Fatalf("expected no mail, got %+v", outbox.messages)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// verifiedUserRow returns a users row for a user with a verified email.
+func verifiedUserRow(id, name, email string) []driver.Value {
+	row := userRow(id, name, "hash", models.RoleMember)
+	row[5] = email
+	row[12] = time.Now()
+	return row
+}
+
+func TestResetPassword_InvalidToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE password_reset_tokens").
+		WithArgs(hashOpaqueToken("used"), "default").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token":"used","password":"secret123"}`))
+	rec := httptest.NewRecorder()
+
+	ResetPassword(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestResetPassword_ShortPassword(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token":"t","password":"123"}`))
+	rec := httptest.NewRecorder()
+
+	var mockDB DB
+	ResetPassword(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestResetPassword_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE password_reset_tokens").
+		WithArgs(hashOpaqueToken("fresh"), "default").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1"))
+	expectSetPassword(mock, "u1")
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token":"fresh","password":"secret123"}`))
+	rec := httptest.NewRecorder()
+
+	ResetPassword(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNoContent, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestChangePassword_RequiresAuth(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+
+	var mockDB DB
+	ChangePassword(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestChangePassword_WrongCurrentPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("alice123"), bcrypt.MinCost)
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", string(hash), "member")...))
+
+	body := `{"current_password":"wrong","new_password":"secret123"}`
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(body)), "u1")
+	rec := httptest.NewRecorder()
+
+	ChangePassword(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestChangePassword_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, _ := bcrypt.GenerateFromPassword([]byte("alice123"), bcrypt.MinCost)
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", string(hash), models.RoleMember)...))
+	expectSetPassword(mock, "u1")
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"current_password":"alice123","new_password":"secret123"}`
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/me/password", strings.NewReader(body)), "u1")
+	rec := httptest.NewRecorder()
+
+	ChangePassword(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"net/http"
 	"strings"
+
+	"platform-go-challenge/models"
 )
 
 func UserRouter(db DB) http.HandlerFunc {
@@ -11,6 +13,8 @@ func UserRouter(db DB) http.HandlerFunc {
 		// POST /users - Create a new user
 		// GET /users - List all users
 		// GET /users/{userID} - Get user by ID
+		// PATCH /users/{userID} - Update user
+		// DELETE /users/{userID} - Delete user
 
 		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
 
@@ -19,6 +23,10 @@ func UserRouter(db DB) http.HandlerFunc {
 			return
 		}
 
+		if !requireScope(w, r, models.ScopeUsersAdmin) {
+			return
+		}
+
 		switch r.Method {
 		case http.MethodPost:
 			// POST /users - Create a new user
@@ -36,13 +44,28 @@ func UserRouter(db DB) http.HandlerFunc {
 				return
 			}
 
-			// case http.MethodDelete:
-			// 	// DELETE /users/{userID} - Delete user
-			// 	if len(parts) == 2 {
-			// 		println("Delete user")
-			// 		DeleteUser(db)(w, r)
-			// 		return
-			// 	}
+			// GET /users/{userID} - Get user by ID
+			if len(parts) == 2 {
+				println("Get user")
+				GetUser(db)(w, r)
+				return
+			}
+
+		case http.MethodPatch:
+			// PATCH /users/{userID} - Update user
+			if len(parts) == 2 {
+				println("Update user")
+				UpdateUser(db)(w, r)
+				return
+			}
+
+		case http.MethodDelete:
+			// DELETE /users/{userID} - Delete user
+			if len(parts) == 2 {
+				println("Delete user")
+				DeleteUser(db)(w, r)
+				return
+			}
 		}
 
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -63,6 +86,14 @@ func FavouritesRouter(db DB) http.HandlerFunc {
 			return
 		}
 
+		scope := models.ScopeFavouritesWrite
+		if r.Method == http.MethodGet {
+			scope = models.ScopeFavouritesRead
+		}
+		if !requireScope(w, r, scope) {
+			return
+		}
+
 		switch r.Method {
 
 		case http.MethodGet:
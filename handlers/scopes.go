@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"platform-go-challenge/models"
+)
+
+// hasScope reports whether the caller's credential grants scope. Scopes only
+// narrow a credential: ones without any scopes are unrestricted, and whether
+// the caller is authenticated at all is left to AuthMiddleware.
+func hasScope(r *http.Request, scope string) bool {
+	claims, ok := claimsFromContext(r.Context())
+	if !authEnabled || !ok || claims.Scope == "" {
+		return true
+	}
+
+	return slices.Contains(strings.Fields(claims.Scope), scope)
+}
+
+// requireScope writes a 403 and returns false unless the caller has scope.
+func requireScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	if !hasScope(r, scope) {
+		http.Error(w, "insufficient scope: "+scope+" required", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// requireFullScope writes a 403 and returns false when the caller's
+// credential lacks any scope of its role, like a read-only widget token.
+// Changes to the account and to organizations are not covered by a scope, so
+// they need a credential that is not narrowed at all.
+func requireFullScope(w http.ResponseWriter, r *http.Request) bool {
+	claims, ok := claimsFromContext(r.Context())
+	if !ok {
+		return true
+	}
+
+	for _, scope := range models.ScopesForRole(claims.Role) {
+		if !hasScope(r, scope) {
+			http.Error(w, "insufficient scope: every scope of the role is required", http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// grantScope resolves the space-separated scopes a client requested for a
+// user with role. An empty request grants every scope the role allows.
+func grantScope(requested, role string) (string, error) {
+	allowed := models.ScopesForRole(role)
+
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(allowed, " "), nil
+	}
+
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+	for _, scope := range scopes {
+		if !models.ValidScope(scope) {
+			return "", fmt.Errorf("unknown scope: %s", scope)
+		}
+		if !slices.Contains(allowed, scope) {
+			return "", fmt.Errorf("scope %s is not allowed for role %s", scope, role)
+		}
+	}
+
+	return strings.Join(scopes, " "), nil
+}
+
+// restrictScope drops the scopes in granted that role no longer allows, e.g.
+// after the user was demoted. An empty granted stands for every scope of the
+// role; the result is empty when nothing is left.
+func restrictScope(granted, role string) string {
+	allowed := models.ScopesForRole(role)
+	if granted == "" {
+		return strings.Join(allowed, " ")
+	}
+
+	var kept []string
+	for _, scope := range strings.Fields(granted) {
+		if slices.Contains(allowed, scope) {
+			kept = append(kept, scope)
+		}
+	}
+
+	return strings.Join(kept, " ")
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/models"
+)
+
+func TestGrantScope(t *testing.T) {
+	tests := []struct {
+		requested string
+		role      string
+		want      string
+		wantErr   bool
+	}{
+		{"", models.RoleMember, "favourites:read favourites:write", false},
+		{"", models.RoleAdmin, "favourites:read favourites:write assets:write users:admin", false},
+		{"favourites:read favourites:read", models.RoleMember, "favourites:read", false},
+		{"users:admin assets:write", models.RoleAdmin, "assets:write users:admin", false},
+		{"assets:write", models.RoleMember, "", true},
+		{"favourites:delete", models.RoleAdmin, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := grantScope(tt.requested, tt.role)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("grantScope(%q, %s) = %q, %v; want %q, error %v", tt.requested, tt.role, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRestrictScope_DropsScopesLostWithRole(t *testing.T) {
+	if got := restrictScope("favourites:read users:admin", models.RoleMember); got != "favourites:read" {
+		t.Fatalf("restrictScope = %q, want favourites:read", got)
+	}
+	if got := restrictScope("users:admin", models.RoleMember); got != "" {
+		t.Fatalf("restrictScope = %q, want empty", got)
+	}
+}
+
+func TestLogin_ReadOnlyTokenCannotModify(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := passwordHasher.Hash("alice123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	expectNoLockout(mock, "u1")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", hash, "admin")...))
+	expectNoTwoFactor(mock, "u1")
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectRecordLogin(mock, "u1")
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), "favourites:read", sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"id":"u1","password":"alice123","scope":"favourites:read"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Scope != "favourites:read" {
+		t.Fatalf("scope = %q, want favourites:read", resp.Scope)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+
+	// Even though the user is an admin, the token cannot modify anything
+	writes := []struct {
+		method  string
+		path    string
+		handler func(DB) http.HandlerFunc
+	}{
+		{http.MethodPost, "/users/u1/favourites", FavouritesRouter},
+		{http.MethodDelete, "/users/u1/favourites/a1", FavouritesRouter},
+		{http.MethodPost, "/assets", AssetsRouter},
+		{http.MethodPost, "/users", UserRouter},
+	}
+
+	for _, write := range writes {
+		req := httptest.NewRequest(write.method, write.path, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+resp.Token)
+		rec := httptest.NewRecorder()
+
+		AuthMiddleware(write.handler(db)).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("%s %s status = %d, want %d", write.method, write.path, rec.Code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestLogin_ScopeNotAllowedForRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := passwordHasher.Hash("bob123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	expectNoLockout(mock, "u2")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", hash, "member")...))
+
+	body := `{"id":"u2","password":"bob123","scope":"users:admin"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// withScope attaches claims as AuthMiddleware would for an access token of
+// subject limited to scope.
+func withScope(req *http.Request, subject, role, scope string) *http.Request {
+	claims := &Claims{Role: role, Scope: scope, RegisteredClaims: jwt.RegisteredClaims{Subject: subject}}
+	return req.WithContext(contextWithClaims(req.Context(), claims))
+}
+
+func TestAccountChanges_RequireFullScope(t *testing.T) {
+	cases := map[string]struct {
+		method  string
+		path    string
+		handler func(DB) http.HandlerFunc
+	}{
+		"update profile":    {http.MethodPatch, "/me", MeRouter},
+		"delete account":    {http.MethodDelete, "/me", MeRouter},
+		"change password":   {http.MethodPost, "/me/password", ChangePassword},
+		"enroll 2fa":        {http.MethodPost, "/me/2fa/enroll", EnrollTwoFactor},
+		"verify 2fa":        {http.MethodPost, "/me/2fa/verify", VerifyTwoFactor},
+		"create org":        {http.MethodPost, "/orgs", OrganizationsRouter},
+		"update org":        {http.MethodPatch, "/orgs/acme", OrganizationsRouter},
+		"add org member":    {http.MethodPost, "/orgs/acme/members", OrganizationsRouter},
+		"remove org member": {http.MethodDelete, "/orgs/acme/members/u2", OrganizationsRouter},
+	}
+
+	for name, tt := range cases {
+		t.Run(name, func(t *testing.T) {
+			// No DB expectations: the scope check must run first
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New error: %v", err)
+			}
+			defer db.Close()
+
+			req := withScope(httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{}`)), "u1", models.RoleMember, models.ScopeFavouritesRead)
+			rec := httptest.NewRecorder()
+
+			tt.handler(db).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
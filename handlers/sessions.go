@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// sessionModeCookie is the value of the "session" login field that asks for
+// tokens to be set as cookies rather than returned in the body.
+const sessionModeCookie = "cookie"
+
+const (
+	sessionCookie = "pgc_session" // access token
+	refreshCookie = "pgc_refresh" // refresh token
+	csrfCookie    = "pgc_csrf"    // double-submit CSRF token, readable by scripts
+	csrfHeader    = "X-CSRF-Token"
+)
+
+var errCSRF = errors.New("Invalid CSRF token")
+
+// cookieSecure sets the Secure attribute on every cookie. Only turn it off
+// for local development over plain HTTP.
+var cookieSecure = true
+
+func init() {
+	// Cookies are Secure unless COOKIE_SECURE=false, e.g. for plain HTTP in dev
+	cookieSecure = os.Getenv("COOKIE_SECURE") != "false"
+}
+
+// sessionResponse is returned instead of tokenResponse for cookie sessions.
+// The tokens themselves are only available to the browser as HttpOnly
+// cookies.
+type sessionResponse struct {
+	TokenType string `json:"token_type"`
+	ExpiresIn int    `json:"expires_in"`
+	Scope     string `json:"scope"`
+	CSRFToken string `json:"csrf_token"`
+}
+
+// validSessionMode reports whether mode is a supported value of the
+// "session" login field; empty means bearer tokens.
+func validSessionMode(mode string) bool {
+	return mode == "" || mode == sessionModeCookie
+}
+
+// writeTokenResponse sends a freshly issued token pair, either as JSON or,
+// for cookie sessions, as cookies along with a new CSRF token.
+func writeTokenResponse(w http.ResponseWriter, resp *tokenResponse, sessionMode string) {
+	if sessionMode != sessionModeCookie {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	csrfToken, err := generateOpaqueToken()
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	setSessionCookie(w, sessionCookie, resp.Token, accessTokenTTL, http.SameSiteLaxMode, true)
+	setSessionCookie(w, refreshCookie, resp.RefreshToken, refreshTokenTTL, http.SameSiteStrictMode, true)
+	// The CSRF cookie must outlive the session so refreshes can be protected
+	setSessionCookie(w, csrfCookie, csrfToken, refreshTokenTTL, http.SameSiteLaxMode, false)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sessionResponse{
+		TokenType: "Cookie",
+		ExpiresIn: resp.ExpiresIn,
+		Scope:     resp.Scope,
+		CSRFToken: csrfToken,
+	})
+}
+
+func setSessionCookie(w http.ResponseWriter, name, value string, ttl time.Duration, sameSite http.SameSite, httpOnly bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: httpOnly,
+		Secure:   cookieSecure,
+		SameSite: sameSite,
+	})
+}
+
+// clearSessionCookies ends a cookie session in the browser.
+func clearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{sessionCookie, refreshCookie, csrfCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name != csrfCookie,
+			Secure:   cookieSecure,
+		})
+	}
+}
+
+// refreshTokenFromRequest returns the refresh token from the request body
+// or, when the body has none, from the refresh cookie. cookie reports the
+// latter; such requests have already passed the CSRF check.
+func refreshTokenFromRequest(r *http.Request) (token string, cookie bool, err error) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// Cookie sessions may send an empty body
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+		return "", false, errors.New("Invalid request body")
+	}
+	if input.RefreshToken != "" {
+		return input.RefreshToken, false, nil
+	}
+
+	c, err := r.Cookie(refreshCookie)
+	if err != nil || c.Value == "" {
+		return "", false, errors.New("refresh_token required")
+	}
+	if !validCSRFToken(r) {
+		return "", false, errCSRF
+	}
+
+	return c.Value, true, nil
+}
+
+// safeMethod reports whether method cannot change state, so cookie
+// authenticated requests using it need no CSRF token.
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// validCSRFToken reports whether the CSRF header matches the CSRF cookie.
+// Other sites can make the browser send the cookie but cannot read it to
+// copy it into the header.
+func validCSRFToken(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+
+	header := r.Header.Get(csrfHeader)
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(header)) == 1
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+// responseCookies returns the cookies set by a response, by name.
+func responseCookies(rec *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := make(map[string]*http.Cookie)
+	for _, c := range rec.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	return cookies
+}
+
+func TestLogin_CookieSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := passwordHasher.Hash("alice123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	expectNoLockout(mock, "u1")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", hash, "member")...))
+	expectNoTwoFactor(mock, "u1")
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectRecordLogin(mock, "u1")
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"id":"u1","password":"alice123","session":"cookie"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if _, ok := resp["token"]; ok {
+		t.Fatalf("tokens must not be returned in the body: %v", resp)
+	}
+
+	cookies := responseCookies(rec)
+	for _, name := range []string{sessionCookie, refreshCookie} {
+		c := cookies[name]
+		if c == nil || c.Value == "" || !c.HttpOnly || !c.Secure || c.SameSite == http.SameSiteDefaultMode {
+			t.Fatalf("cookie %s = %+v, want a secure HttpOnly SameSite cookie", name, c)
+		}
+	}
+	csrf := cookies[csrfCookie]
+	if csrf == nil || csrf.HttpOnly || csrf.Value != resp["csrf_token"] {
+		t.Fatalf("csrf cookie = %+v, want a script readable cookie matching %v", csrf, resp["csrf_token"])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAuthMiddleware_CookieSessionRequiresCSRFToken(t *testing.T) {
+	token, err := IssueToken(&models.User{ID: "u1", Role: models.RoleMember})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	cases := map[string]struct {
+		method string
+		csrf   string
+		want   int
+	}{
+		"read without token":     {http.MethodGet, "", http.StatusOK},
+		"write without token":    {http.MethodPost, "", http.StatusForbidden},
+		"write with wrong token": {http.MethodDelete, "forged", http.StatusForbidden},
+		"write with token":       {http.MethodPost, "csrf-1", http.StatusOK},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/users/u1/favourites", nil)
+			req.AddCookie(&http.Cookie{Name: sessionCookie, Value: token})
+			req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf-1"})
+			if tc.csrf != "" {
+				req.Header.Set(csrfHeader, tc.csrf)
+			}
+			rec := httptest.NewRecorder()
+
+			AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}).ServeHTTP(rec, req)
+
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddleware_BearerIgnoresCSRF(t *testing.T) {
+	token, err := IssueToken(&models.User{ID: "u1", Role: models.RoleMember})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/users/u1/favourites", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+}
+
+func TestRefreshToken_FromCookie(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	newRequest := func(csrf string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/token/refresh", nil)
+		req.AddCookie(&http.Cookie{Name: refreshCookie, Value: "old-token"})
+		req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf-1"})
+		req.Header.Set(csrfHeader, csrf)
+		return req
+	}
+
+	// Without the CSRF token nothing is consumed
+	rec := httptest.NewRecorder()
+	RefreshToken(db).ServeHTTP(rec, newRequest(""))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status without CSRF token = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE refresh_tokens").
+		WithArgs(hashOpaqueToken("old-token"), "default").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+			AddRow(hashOpaqueToken("old-token"), "u1", "fam1", "", now.Add(time.Hour), now, now, ""))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", "fam1", sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rec = httptest.NewRecorder()
+	RefreshToken(db).ServeHTTP(rec, newRequest("csrf-1"))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if c := responseCookies(rec)[refreshCookie]; c == nil || c.Value == "" || c.Value == "old-token" {
+		t.Fatalf("expected a rotated refresh cookie, got %+v", c)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLogout_CookieSessionClearsCookies(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT token_hash").
+		WithArgs(hashOpaqueToken("old-token"), "default").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "family_id", "scope", "expires_at", "rotated_at", "revoked_at", "created_at"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: refreshCookie, Value: "old-token"})
+	req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf-1"})
+	req.Header.Set(csrfHeader, "csrf-1")
+	rec := httptest.NewRecorder()
+
+	Logout(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	for _, name := range []string{sessionCookie, refreshCookie, csrfCookie} {
+		if c := responseCookies(rec)[name]; c == nil || c.MaxAge >= 0 {
+			t.Fatalf("cookie %s = %+v, want it cleared", name, c)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
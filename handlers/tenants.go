@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"platform-go-challenge/repositories"
+)
+
+var errWrongTenant = errors.New("Token was issued for another tenant")
+
+// tenantHosts maps request hosts to the tenant they serve. Requests to other
+// hosts belong to the tenant of their token, or to the default tenant.
+var tenantHosts = map[string]string{}
+
+func init() {
+	// Tenants served on their own hosts, e.g. TENANT_HOSTS=acme.example.com=acme,globex.example.com=globex
+	tenantHosts = parseTenantHosts(os.Getenv("TENANT_HOSTS"))
+}
+
+// parseTenantHosts parses a comma-separated list of host=tenant pairs.
+func parseTenantHosts(s string) map[string]string {
+	hosts := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		host, tenant, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || host == "" || tenant == "" {
+			continue
+		}
+		hosts[strings.ToLower(host)] = tenant
+	}
+
+	return hosts
+}
+
+// hostTenant returns the tenant mapped to the host of r, if any.
+func hostTenant(r *http.Request) (string, bool) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	tenant, ok := tenantHosts[strings.ToLower(host)]
+	return tenant, ok
+}
+
+// TenantMiddleware scopes the repositories to the tenant of the request host,
+// so that logins, registrations and API keys only see the users of it.
+func TenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenant, ok := hostTenant(r); ok {
+			r = r.WithContext(repositories.WithTenant(r.Context(), tenant))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorizeTenant scopes ctx to the tenant claims were issued for. Tokens
+// are refused on hosts that serve another tenant.
+func authorizeTenant(r *http.Request, claims *Claims) (context.Context, error) {
+	tenant := claims.TenantID
+	if tenant == "" {
+		tenant = repositories.DefaultTenant
+	}
+
+	if host, ok := hostTenant(r); ok && host != tenant {
+		return nil, errWrongTenant
+	}
+
+	return repositories.WithTenant(r.Context(), tenant), nil
+}
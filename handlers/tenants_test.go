@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+// expectUserInTenant expects the check that userID belongs to the default
+// tenant, and finds it.
+func expectUserInTenant(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(userID, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+}
+
+func TestAuthMiddleware_TokenOfOtherTenantRejected(t *testing.T) {
+	origAuthEnabled, origSecret, origHosts := authEnabled, jwtSecret, tenantHosts
+	defer func() { authEnabled, jwtSecret, tenantHosts = origAuthEnabled, origSecret, origHosts }()
+	authEnabled = true
+	jwtSecret = []byte("test-secret")
+	tenantHosts = parseTenantHosts("globex.example.com=globex")
+
+	token, err := IssueToken(&models.User{ID: "u1", Role: models.RoleMember})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	called := false
+	next := func(w http.ResponseWriter, r *http.Request) { called = true }
+
+	req := httptest.NewRequest(http.MethodGet, "http://globex.example.com:8080/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	AuthMiddleware(next).ServeHTTP(rec, req)
+
+	if called {
+		t.Fatal("next handler called with a token of another tenant")
+	}
+	if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), errWrongTenant.Error()) {
+		t.Fatalf("status = %d, body = %q, want 401 wrong tenant", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuthMiddleware_ScopesRequestToTokenTenant(t *testing.T) {
+	origAuthEnabled, origSecret, origHosts := authEnabled, jwtSecret, tenantHosts
+	defer func() { authEnabled, jwtSecret, tenantHosts = origAuthEnabled, origSecret, origHosts }()
+	authEnabled = true
+	jwtSecret = []byte("test-secret")
+	tenantHosts = parseTenantHosts("globex.example.com=globex")
+
+	token, err := IssueToken(&models.User{ID: "u1", Role: models.RoleMember, TenantID: "globex"})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	var tenant string
+	next := func(w http.ResponseWriter, r *http.Request) {
+		tenant = repositories.TenantFromContext(r.Context())
+	}
+
+	for _, host := range []string{"globex.example.com", "localhost"} {
+		tenant = ""
+		req := httptest.NewRequest(http.MethodGet, "http://"+host+"/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		TenantMiddleware(AuthMiddleware(next)).ServeHTTP(rec, req)
+
+		if tenant != "globex" {
+			t.Fatalf("host %s: tenant = %q, want globex", host, tenant)
+		}
+	}
+}
+
+func TestTenantMiddleware_UsesHostTenant(t *testing.T) {
+	origHosts := tenantHosts
+	defer func() { tenantHosts = origHosts }()
+	tenantHosts = parseTenantHosts("Globex.example.com=globex, broken, =x")
+
+	if len(tenantHosts) != 1 {
+		t.Fatalf("tenantHosts = %v, want only globex", tenantHosts)
+	}
+
+	cases := map[string]string{
+		"globex.example.com:8443": "globex",
+		"acme.example.com":        repositories.DefaultTenant,
+	}
+	for host, want := range cases {
+		var tenant string
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant = repositories.TenantFromContext(r.Context())
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "http://"+host+"/login", nil)
+		TenantMiddleware(next).ServeHTTP(httptest.NewRecorder(), req)
+
+		if tenant != want {
+			t.Fatalf("host %s: tenant = %q, want %q", host, tenant, want)
+		}
+	}
+}
+
+func TestAddFavourite_AssetOfOtherTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "globex").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
+	// The asset only exists in the default tenant
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectExec("INSERT INTO favourites").
+		WithArgs("u1", "a1", nil, "globex", "u1", false).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/users/u1/favourites", strings.NewReader(`{"asset_id":"a1"}`)), "u1")
+	req = req.WithContext(repositories.WithTenant(req.Context(), "globex"))
+	rec := httptest.NewRecorder()
+
+	AddFavourite(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusNotFound, rec.Body.String())
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetUser_OtherTenantNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "globex").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	// An admin of globex looking up a user of the default tenant
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/users/u1", nil), "g1", models.RoleAdmin)
+	req = req.WithContext(repositories.WithTenant(req.Context(), "globex"))
+	rec := httptest.NewRecorder()
+
+	UserRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+)
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+	OrgID        string `json:"org,omitempty"`
+}
+
+// generateOpaqueToken returns a random URL-safe token with 256 bits of entropy.
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashOpaqueToken returns the hex SHA-256 of token, which is what gets stored.
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueTokenPair signs an access token for user limited to scope and stores a
+// new refresh token for the same scope in familyID. An empty familyID starts
+// a new family (i.e. a new login). When org is set, both tokens are for
+// acting in that organization.
+func issueTokenPair(ctx context.Context, db *sql.DB, user *models.User, familyID, scope string, org *models.OrganizationMember) (*tokenResponse, error) {
+	claims := accessClaims(user, restrictScope(scope, user.Role))
+	if org != nil {
+		claims.OrgID, claims.OrgRole = org.OrgID, org.Role
+	}
+
+	accessToken, err := signAccessToken(claims, accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		familyID = hex.EncodeToString(b)
+	}
+
+	err = repositories.CreateRefreshToken(ctx, db, models.RefreshToken{
+		TokenHash: hashOpaqueToken(refreshToken),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		Scope:     scope,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+		OrgID:     claims.OrgID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(accessTokenTTL.Seconds()),
+		Scope:        scope,
+		OrgID:        claims.OrgID,
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair.
+// Each refresh token can be used once; presenting one that was already
+// rotated is treated as theft and revokes every token in its family.
+func RefreshToken(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		refreshToken, fromCookie, err := refreshTokenFromRequest(r)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errCSRF) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		sessionMode := ""
+		if fromCookie {
+			sessionMode = sessionModeCookie
+		}
+
+		tokenHash := hashOpaqueToken(refreshToken)
+
+		current, err := repositories.ConsumeRefreshToken(r.Context(), db.(*sql.DB), tokenHash)
+		if err != nil {
+			http.Error(w, "Failed to refresh token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if current == nil {
+			existing, err := repositories.GetRefreshToken(r.Context(), db.(*sql.DB), tokenHash)
+			if err != nil {
+				http.Error(w, "Failed to refresh token: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if existing != nil && (existing.RotatedAt != nil || existing.RevokedAt != nil) {
+				// A rotated token coming back means it was stolen, or the
+				// client is broken; either way the whole family goes
+				log.Printf("refresh token reuse: user=%s family=%s ip=%s", existing.UserID, existing.FamilyID, clientIP(r))
+				if err := repositories.RevokeRefreshTokenFamily(r.Context(), db.(*sql.DB), existing.FamilyID); err != nil {
+					http.Error(w, "Failed to revoke tokens: "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), current.UserID)
+		if err != nil || user == nil || user.DisabledAt != nil || user.DeletedAt != nil {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		// Keep the scopes of the login, minus any the user's role lost since
+		scope := restrictScope(current.Scope, user.Role)
+		if scope == "" {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		// Stay in the organization of the login while still a member of it
+		var org *models.OrganizationMember
+		if current.OrgID != "" {
+			org, err = repositories.GetOrganizationMember(r.Context(), db.(*sql.DB), current.OrgID, user.ID)
+			if err != nil {
+				http.Error(w, "Failed to refresh token: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		resp, err := issueTokenPair(r.Context(), db.(*sql.DB), user, current.FamilyID, scope, org)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		writeTokenResponse(w, resp, sessionMode)
+	}
+}
+
+// Logout revokes the family of the given refresh token. It always succeeds so
+// that callers cannot probe which tokens exist.
+func Logout(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		refreshToken, fromCookie, err := refreshTokenFromRequest(r)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errCSRF) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		existing, err := repositories.GetRefreshToken(r.Context(), db.(*sql.DB), hashOpaqueToken(refreshToken))
+		if err != nil {
+			http.Error(w, "Failed to logout: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if existing != nil {
+			if err := repositories.RevokeRefreshTokenFamily(r.Context(), db.(*sql.DB), existing.FamilyID); err != nil {
+				http.Error(w, "Failed to logout: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if fromCookie {
+			clearSessionCookies(w)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RevokeTokens revokes a single access token by jti, or every access and
+// refresh token of a user: POST /tokens/revoke
+func RevokeTokens(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !requireScope(w, r, models.ScopeUsersAdmin) {
+			return
+		}
+
+		var input struct {
+			JTI    string `json:"jti"`
+			UserID string `json:"user_id"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if (input.JTI == "") == (input.UserID == "") {
+			http.Error(w, "Exactly one of jti or user_id is required", http.StatusBadRequest)
+			return
+		}
+
+		if input.UserID != "" {
+			if err := revokeAllUserTokens(r.Context(), db.(*sql.DB), input.UserID); err != nil {
+				http.Error(w, "Failed to revoke tokens: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// No access token outlives the TTL, so the entry can be purged after it
+		expiresAt := time.Now().Add(accessTokenTTL)
+		if err := repositories.RevokeToken(r.Context(), db.(*sql.DB), input.JTI, nil, expiresAt); err != nil {
+			http.Error(w, "Failed to revoke token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if denylist != nil {
+			denylist.revokeToken(input.JTI, expiresAt)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var refreshTokenColumns = []string{"token_hash", "user_id", "family_id", "scope", "expires_at", "rotated_at", "created_at", "org_id"}
+
+// expectRecordLogin expects a login of userID to be recorded.
+func expectRecordLogin(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectExec("UPDATE users SET last_login_at").
+		WithArgs(userID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestLogin_ReturnsTokenPair(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("alice123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt error: %v", err)
+	}
+
+	expectNoLockout(mock, "u1")
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", string(hash), "member")...))
+
+	// bcrypt hashes are upgraded to argon2id on successful login
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u1", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	expectNoTwoFactor(mock, "u1")
+
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectRecordLogin(mock, "u1")
+
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"id":"u1","password":"alice123"}`
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatalf("expected access and refresh tokens, got %+v", resp)
+	}
+	if resp.ExpiresIn != int(accessTokenTTL.Seconds()) {
+		t.Fatalf("expires_in = %d, want %d", resp.ExpiresIn, int(accessTokenTTL.Seconds()))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRefreshToken_MissingToken(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+
+	RefreshToken(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestRefreshToken_RotatesToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE refresh_tokens").
+		WithArgs(hashOpaqueToken("old-token"), "default").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns).
+			AddRow(hashOpaqueToken("old-token"), "u1", "fam1", "favourites:read", now.Add(time.Hour), now, now, ""))
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
+
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", "fam1", "favourites:read", sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token":"old-token"}`))
+	rec := httptest.NewRecorder()
+
+	RefreshToken(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.RefreshToken == "" || resp.RefreshToken == "old-token" {
+		t.Fatalf("expected a new refresh token, got %q", resp.RefreshToken)
+	}
+	if resp.Scope != "favourites:read" {
+		t.Fatalf("scope = %q, want the scope of the login", resp.Scope)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE refresh_tokens").
+		WithArgs(hashOpaqueToken("used-token"), "default").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns))
+
+	mock.ExpectQuery("SELECT token_hash").
+		WithArgs(hashOpaqueToken("used-token"), "default").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "family_id", "scope", "expires_at", "rotated_at", "revoked_at", "created_at"}).
+			AddRow(hashOpaqueToken("used-token"), "u1", "fam1", "", now.Add(time.Hour), now, nil, now))
+
+	mock.ExpectExec("UPDATE refresh_tokens").
+		WithArgs("fam1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token":"used-token"}`))
+	rec := httptest.NewRecorder()
+
+	RefreshToken(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRefreshToken_UnknownToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE refresh_tokens").
+		WithArgs(hashOpaqueToken("bogus"), "default").
+		WillReturnRows(sqlmock.NewRows(refreshTokenColumns))
+
+	mock.ExpectQuery("SELECT token_hash").
+		WithArgs(hashOpaqueToken("bogus"), "default").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "family_id", "scope", "expires_at", "rotated_at", "revoked_at", "created_at"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token":"bogus"}`))
+	rec := httptest.NewRecorder()
+
+	RefreshToken(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLogout_RevokesFamily(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT token_hash").
+		WithArgs(hashOpaqueToken("token"), "default").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "family_id", "scope", "expires_at", "rotated_at", "revoked_at", "created_at"}).
+			AddRow(hashOpaqueToken("token"), "u1", "fam1", "", now.Add(time.Hour), nil, nil, now))
+
+	mock.ExpectExec("UPDATE refresh_tokens").
+		WithArgs("fam1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(`{"refresh_token":"token"}`))
+	rec := httptest.NewRecorder()
+
+	Logout(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevokeTokens_RequiresExactlyOneTarget(t *testing.T) {
+	for _, body := range []string{`{}`, `{"jti":"a","user_id":"u1"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/tokens/revoke", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+
+		var mockDB DB
+		RevokeTokens(mockDB).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestRevokeTokens_ByJTI(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	d := useDenylist(t)
+
+	mock.ExpectExec("INSERT INTO revoked_tokens").
+		WithArgs("stolen", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/tokens/revoke", strings.NewReader(`{"jti":"stolen"}`))
+	rec := httptest.NewRecorder()
+
+	RevokeTokens(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if !d.isRevoked(&Claims{RegisteredClaims: jwt.RegisteredClaims{ID: "stolen"}}) {
+		t.Fatal("expected jti to be revoked in the local cache")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevokeTokens_ByUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	d := useDenylist(t)
+	justIssued := jwt.NewNumericDate(time.Now())
+
+	mock.ExpectExec("INSERT INTO user_token_revocations").
+		WithArgs("u2", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens").
+		WithArgs("u2", "default").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	req := httptest.NewRequest(http.MethodPost, "/tokens/revoke", strings.NewReader(`{"user_id":"u2"}`))
+	rec := httptest.NewRecorder()
+
+	RevokeTokens(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+
+	old := jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	if !d.isRevoked(&Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u2", IssuedAt: old}}) {
+		t.Fatal("expected earlier tokens of u2 to be revoked")
+	}
+	if !d.isRevoked(&Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "u2", IssuedAt: justIssued}}) {
+		t.Fatal("expected a token of u2 issued in the same second to be revoked")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
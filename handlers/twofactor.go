@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/repositories"
+	"platform-go-challenge/totp"
+)
+
+// tokenUseTwoFactorChallenge marks tokens returned by Login for accounts with
+// two-factor authentication. They are only accepted by LoginTwoFactor.
+const tokenUseTwoFactorChallenge = "2fa_challenge"
+
+const recoveryCodeCount = 10
+
+var twoFactorChallengeTTL = 5 * time.Minute
+var totpIssuer = "Platform Go Challenge"
+
+func init() {
+	// Two-factor authentication, e.g. TOTP_ISSUER=Acme TWO_FACTOR_CHALLENGE_TTL=5m
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		totpIssuer = issuer
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("TWO_FACTOR_CHALLENGE_TTL")); err == nil && ttl > 0 {
+		twoFactorChallengeTTL = ttl
+	}
+}
+
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// issueTwoFactorChallenge signs a short-lived token proving that user passed
+// the password step of Login, remembering the scope granted there and
+// whether the login restores a deleted account.
+func issueTwoFactorChallenge(user *models.User, scope string, restore bool) (*twoFactorChallengeResponse, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	token, err := signToken(Claims{
+		TokenUse: tokenUseTwoFactorChallenge,
+		Scope:    scope,
+		Restore:  restore,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(jti),
+			Subject:   user.ID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(twoFactorChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &twoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(twoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+// generateRecoveryCode returns a random code formatted as xxxx-xxxx-xxxx-xxxx.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+// hashRecoveryCode hashes code ignoring case, dashes and spaces, so users can
+// type it however it was written down.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return hashOpaqueToken(code)
+}
+
+// EnrollTwoFactor starts TOTP enrollment for the caller: POST /me/2fa/enroll
+// The returned secret only becomes active once confirmed with VerifyTwoFactor.
+func EnrollTwoFactor(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if rejectImpersonation(w, r) {
+			return
+		}
+
+		if !requireFullScope(w, r) {
+			return
+		}
+
+		enrollment, err := repositories.GetTOTPEnrollment(r.Context(), db.(*sql.DB), claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to fetch two-factor status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if enrollment != nil && enrollment.EnabledAt != nil {
+			http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+
+		if err := repositories.SaveTOTPSecret(r.Context(), db.(*sql.DB), claims.Subject, secret); err != nil {
+			http.Error(w, "Failed to save secret: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{
+			"secret":      secret,
+			"otpauth_uri": totp.URI(totpIssuer, claims.Subject, secret),
+		})
+	}
+}
+
+// VerifyTwoFactor confirms a pending enrollment with a code from the
+// authenticator app and enables two-factor authentication: POST /me/2fa/verify
+// The recovery codes are only returned here, in plain text, once.
+func VerifyTwoFactor(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		claims, ok := claimsFromContext(r.Context())
+		if !ok || claims.Subject == "" {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		if rejectImpersonation(w, r) {
+			return
+		}
+
+		if !requireFullScope(w, r) {
+			return
+		}
+
+		var input struct {
+			Code string `json:"code"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil || input.Code == "" {
+			http.Error(w, "Code required", http.StatusBadRequest)
+			return
+		}
+
+		enrollment, err := repositories.GetTOTPEnrollment(r.Context(), db.(*sql.DB), claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to fetch two-factor status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if enrollment == nil {
+			http.Error(w, "No pending two-factor enrollment", http.StatusBadRequest)
+			return
+		}
+		if enrollment.EnabledAt != nil {
+			http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+			return
+		}
+
+		step, ok := totp.Validate(enrollment.Secret, input.Code, time.Now())
+		if !ok {
+			http.Error(w, "Invalid code", http.StatusBadRequest)
+			return
+		}
+
+		codes := make([]string, recoveryCodeCount)
+		hashes := make([]string, recoveryCodeCount)
+		for i := range codes {
+			code, err := generateRecoveryCode()
+			if err != nil {
+				http.Error(w, "Failed to generate recovery codes", http.StatusInternalServerError)
+				return
+			}
+			codes[i], hashes[i] = code, hashRecoveryCode(code)
+		}
+
+		if err := repositories.EnableTOTP(r.Context(), db.(*sql.DB), claims.Subject, step, hashes); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to enable two-factor authentication: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string][]string{
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// LoginTwoFactor completes a login for an account with two-factor
+// authentication: POST /login/2fa
+// It takes the challenge token returned by Login plus either a TOTP code or
+// one of the recovery codes, and returns the usual token pair.
+func LoginTwoFactor(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var input struct {
+			ChallengeToken string `json:"challenge_token"`
+			Code           string `json:"code"`
+			RecoveryCode   string `json:"recovery_code"`
+			Session        string `json:"session"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if input.ChallengeToken == "" || (input.Code == "" && input.RecoveryCode == "") {
+			http.Error(w, "Challenge token and code or recovery code required", http.StatusBadRequest)
+			return
+		}
+
+		if !validSessionMode(input.Session) {
+			http.Error(w, "Invalid session mode", http.StatusBadRequest)
+			return
+		}
+
+		claims := &Claims{}
+		token, err := jwt.ParseWithClaims(input.ChallengeToken, claims, verificationKey)
+		if err != nil || !token.Valid || claims.TokenUse != tokenUseTwoFactorChallenge ||
+			(denylist != nil && denylist.isRevoked(claims)) {
+			http.Error(w, "Invalid or expired challenge token", http.StatusUnauthorized)
+			return
+		}
+
+		// Wrong codes count towards the same lockout as wrong passwords
+		if !checkLoginLockout(w, r, db.(*sql.DB), claims.Subject) {
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to verify credentials", http.StatusInternalServerError)
+			return
+		}
+
+		enrollment, err := repositories.GetTOTPEnrollment(r.Context(), db.(*sql.DB), claims.Subject)
+		if err != nil {
+			http.Error(w, "Failed to fetch two-factor status: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if user == nil || enrollment == nil || enrollment.EnabledAt == nil {
+			http.Error(w, "Invalid or expired challenge token", http.StatusUnauthorized)
+			return
+		}
+
+		// The account may have been disabled after the password step
+		if user.DisabledAt != nil {
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+			return
+		}
+		if !checkAccountDeleted(w, user, claims.Restore) {
+			return
+		}
+
+		verified := false
+		if input.Code != "" {
+			if step, ok := totp.Validate(enrollment.Secret, input.Code, time.Now()); ok {
+				// Each code can only be used once
+				verified, err = repositories.UseTOTPStep(r.Context(), db.(*sql.DB), user.ID, step)
+			}
+		} else {
+			verified, err = repositories.ConsumeRecoveryCode(r.Context(), db.(*sql.DB), user.ID, hashRecoveryCode(input.RecoveryCode))
+		}
+		if err != nil {
+			http.Error(w, "Failed to verify code: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !verified {
+			if err := recordLoginFailure(r.Context(), db.(*sql.DB), clientIP(r), user.ID); err != nil {
+				println("Error recording login failure:", err.Error())
+			}
+			http.Error(w, "Invalid two-factor code", http.StatusUnauthorized)
+			return
+		}
+
+		if _, err := repositories.ClearLoginFailures(r.Context(), db.(*sql.DB), accountLockoutKey(user.ID)); err != nil {
+			println("Error clearing login failures:", err.Error())
+		}
+		if err := recordLogin(r, db.(*sql.DB), user.ID, models.LoginMethodTwoFactor); err != nil {
+			println("Error recording login:", err.Error())
+		}
+
+		scope := restrictScope(claims.Scope, user.Role)
+		if scope == "" {
+			http.Error(w, "Invalid or expired challenge token", http.StatusUnauthorized)
+			return
+		}
+
+		if err := restoreAccount(r, db.(*sql.DB), user); err != nil {
+			http.Error(w, "Failed to restore account: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		resp, err := issueTokenPair(r.Context(), db.(*sql.DB), user, "", scope, nil)
+		if err != nil {
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		writeTokenResponse(w, resp, input.Session)
+	}
+}
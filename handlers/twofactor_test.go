@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+	"platform-go-challenge/totp"
+)
+
+var totpColumns = []string{"user_id", "secret", "enabled_at", "last_used_step"}
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// expectNoTwoFactor expects the two-factor lookup for userID to find nothing.
+func expectNoTwoFactor(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectQuery("SELECT user_id, secret, enabled_at, last_used_step").
+		WithArgs(userID, "default").
+		WillReturnRows(sqlmock.NewRows(totpColumns))
+}
+
+// expectTwoFactorEnabled expects the two-factor lookup for userID to find an
+// enabled enrollment with testTOTPSecret.
+func expectTwoFactorEnabled(mock sqlmock.Sqlmock, userID string) {
+	mock.ExpectQuery("SELECT user_id, secret, enabled_at, last_used_step").
+		WithArgs(userID, "default").
+		WillReturnRows(sqlmock.NewRows(totpColumns).AddRow(userID, testTOTPSecret, time.Now(), nil))
+}
+
+func TestLogin_TwoFactorReturnsChallenge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := passwordHasher.Hash("alice123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	expectNoLockout(mock, "u1")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", hash, "member")...))
+	expectTwoFactorEnabled(mock, "u1")
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"id":"u1","password":"alice123"}`))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["two_factor_required"] != true || resp["challenge_token"] == "" {
+		t.Fatalf("expected a challenge, got %v", resp)
+	}
+	if _, ok := resp["token"]; ok {
+		t.Fatalf("expected no access token before the second factor, got %v", resp)
+	}
+
+	// The challenge token is not an access token
+	protected := httptest.NewRequest(http.MethodGet, "/assets", nil)
+	protected.Header.Set("Authorization", "Bearer "+resp["challenge_token"].(string))
+	rec = httptest.NewRecorder()
+
+	AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("challenge token reached the protected handler")
+	}).ServeHTTP(rec, protected)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("protected status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLoginTwoFactor_ValidCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	challenge, err := issueTwoFactorChallenge(&models.User{ID: "u1"}, "favourites:read", false)
+	if err != nil {
+		t.Fatalf("issueTwoFactorChallenge error: %v", err)
+	}
+	code, _ := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+
+	expectNoLockout(mock, "u1")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
+	expectTwoFactorEnabled(mock, "u1")
+	mock.ExpectExec("UPDATE user_totp").
+		WithArgs("u1", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectRecordLogin(mock, "u1")
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"challenge_token":"` + challenge.ChallengeToken + `","code":"` + code + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	LoginTwoFactor(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Token == "" || resp.RefreshToken == "" {
+		t.Fatalf("expected access and refresh tokens, got %+v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLoginTwoFactor_UsedRecoveryCodeCountsFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	challenge, err := issueTwoFactorChallenge(&models.User{ID: "u1"}, "favourites:read", false)
+	if err != nil {
+		t.Fatalf("issueTwoFactorChallenge error: %v", err)
+	}
+
+	expectNoLockout(mock, "u1")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u1", "Alice", "hash", "member")...))
+	expectTwoFactorEnabled(mock, "u1")
+	mock.ExpectExec("UPDATE user_recovery_codes").
+		WithArgs(hashRecoveryCode("abcd-efgh-ijkl-mnop"), "u1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:192.0.2.1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("user:u1", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(1))
+
+	body := `{"challenge_token":"` + challenge.ChallengeToken + `","recovery_code":"ABCD EFGH IJKL MNOP"}`
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	LoginTwoFactor(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLoginTwoFactor_RejectsAccessToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	accessToken, err := IssueToken(&models.User{ID: "u1", Role: models.RoleMember})
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	body := `{"challenge_token":"` + accessToken + `","code":"123456"}`
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	LoginTwoFactor(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnrollTwoFactor_ReturnsOtpauthURI(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectNoTwoFactor(mock, "u1")
+	expectUserInTenant(mock, "u1")
+	mock.ExpectExec("INSERT INTO user_totp").
+		WithArgs("u1", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/me/2fa/enroll", nil), "u1")
+	rec := httptest.NewRecorder()
+
+	EnrollTwoFactor(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["secret"] == "" || !strings.HasPrefix(resp["otpauth_uri"], "otpauth://totp/") ||
+		!strings.Contains(resp["otpauth_uri"], "secret="+resp["secret"]) {
+		t.Fatalf("unexpected enrollment response: %v", resp)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnrollTwoFactor_AlreadyEnabled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectTwoFactorEnabled(mock, "u1")
+
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/me/2fa/enroll", nil), "u1")
+	rec := httptest.NewRecorder()
+
+	EnrollTwoFactor(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestVerifyTwoFactor_EnablesAndReturnsRecoveryCodes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	code, _ := totp.Code(testTOTPSecret, totp.Step(time.Now()))
+
+	mock.ExpectQuery("SELECT user_id, secret, enabled_at, last_used_step").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(totpColumns).AddRow("u1", testTOTPSecret, nil, nil))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp").
+		WithArgs("u1", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user_recovery_codes").
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	for i := 0; i < recoveryCodeCount; i++ {
+		mock.ExpectExec("INSERT INTO user_recovery_codes").
+			WithArgs(sqlmock.AnyArg(), "u1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/me/2fa/verify", strings.NewReader(`{"code":"`+code+`"}`)), "u1")
+	rec := httptest.NewRecorder()
+
+	VerifyTwoFactor(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp map[string][]string
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp["recovery_codes"]) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(resp["recovery_codes"]), recoveryCodeCount)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestVerifyTwoFactor_InvalidCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT user_id, secret, enabled_at, last_used_step").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(totpColumns).AddRow("u1", testTOTPSecret, nil, nil))
+
+	req := withSubject(httptest.NewRequest(http.MethodPost, "/me/2fa/verify", strings.NewReader(`{"code":"000000x"}`)), "u1")
+	rec := httptest.NewRecorder()
+
+	VerifyTwoFactor(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -3,16 +3,76 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
-
 	"platform-go-challenge/models"
 	"platform-go-challenge/repositories"
 )
 
+const (
+	minPasswordLength    = 6
+	maxDisplayNameLength = 100
+)
+
+// errUserIDTaken does not say whether the ID is taken in this tenant or
+// another one.
+var errUserIDTaken = errors.New("User ID already taken")
+
+// validateProfile checks the optional profile fields of a user; empty values
+// are not set.
+func validateProfile(email, displayName, avatarURL, locale string) error {
+	if email != "" && !models.ValidEmail(email) {
+		return errors.New("Invalid email")
+	}
+	if len(displayName) > maxDisplayNameLength {
+		return fmt.Errorf("Display name must be at most %d characters", maxDisplayNameLength)
+	}
+	if avatarURL != "" && !models.ValidAvatarURL(avatarURL) {
+		return errors.New("Avatar URL must be an absolute http(s) URL")
+	}
+	if locale != "" && !models.ValidLocale(locale) {
+		return errors.New("Invalid locale")
+	}
+	return nil
+}
+
+// validateProfileUpdate checks the fields set in update.
+func validateProfileUpdate(update *models.UserProfileUpdate) error {
+	if update.Name != nil {
+		*update.Name = strings.TrimSpace(*update.Name)
+		if *update.Name == "" {
+			return errors.New("Name cannot be empty")
+		}
+	}
+
+	var displayName, avatarURL, locale string
+	if update.DisplayName != nil {
+		*update.DisplayName = strings.TrimSpace(*update.DisplayName)
+		displayName = *update.DisplayName
+	}
+	if update.AvatarURL != nil {
+		avatarURL = *update.AvatarURL
+	}
+	if update.Locale != nil {
+		locale = *update.Locale
+	}
+	return validateProfile("", displayName, avatarURL, locale)
+}
+
+// emailInUse reports whether another user already has email, ignoring case.
+func emailInUse(r *http.Request, db *sql.DB, email string) (bool, error) {
+	if email == "" {
+		return false, nil
+	}
+
+	user, err := repositories.GetUserByEmail(r.Context(), db, email)
+	return user != nil, err
+}
+
 func AddUser(db DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -32,7 +92,47 @@ func AddUser(db DB) http.HandlerFunc {
 			return
 		}
 
+		// Email addresses identify users at login alongside IDs
+		if strings.Contains(user.ID, "@") {
+			http.Error(w, "ID cannot contain @", http.StatusBadRequest)
+			return
+		}
+
+		if user.Role == "" {
+			user.Role = models.RoleMember
+		}
+		if !models.ValidRole(user.Role) {
+			http.Error(w, "Role must be admin or member", http.StatusBadRequest)
+			return
+		}
+
+		user.Email = strings.TrimSpace(user.Email)
+		user.DisplayName = strings.TrimSpace(user.DisplayName)
+		if err := validateProfile(user.Email, user.DisplayName, user.AvatarURL, user.Locale); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		inUse, err := emailInUse(r, db.(*sql.DB), user.Email)
+		if err != nil {
+			http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if inUse {
+			http.Error(w, "Email already in use", http.StatusConflict)
+			return
+		}
+
+		// Status and timestamps are managed by the server
+		user.DisabledAt, user.LastLoginAt, user.EmailVerifiedAt = nil, nil, nil
+		user.CreatedAt = time.Now()
+		user.UpdatedAt = user.CreatedAt
+
 		userID, err := repositories.CreateUser(r.Context(), db.(*sql.DB), user)
+		if errors.Is(err, repositories.ErrUserExists) {
+			http.Error(w, errUserIDTaken.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			println("Error creating user:", err.Error())
 			http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
@@ -40,6 +140,12 @@ func AddUser(db DB) http.HandlerFunc {
 		}
 
 		user.ID = userID
+		if user.Email != "" {
+			if err := sendEmailVerification(r, db.(*sql.DB), &user); err != nil {
+				println("Error sending verification email:", err.Error())
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(user)
@@ -65,7 +171,257 @@ func GetUsers(db DB) http.HandlerFunc {
 	}
 }
 
-// Login authenticates user and returns JWT token
+// UpdateUserRole promotes or demotes a user: PUT /users/{userID}/role
+func UpdateUserRole(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !requireScope(w, r, models.ScopeUsersAdmin) {
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[0] != "users" || parts[2] != "role" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		userID := parts[1]
+
+		var input struct {
+			Role string `json:"role"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if !models.ValidRole(input.Role) {
+			http.Error(w, "Role must be admin or member", http.StatusBadRequest)
+			return
+		}
+
+		// Stop admins from locking themselves out of user administration
+		if claims, ok := claimsFromContext(r.Context()); ok && claims.Subject == userID {
+			http.Error(w, "Cannot change your own role", http.StatusBadRequest)
+			return
+		}
+
+		err := repositories.UpdateUserRole(r.Context(), db.(*sql.DB), userID, input.Role)
+		if err != nil {
+			if errors.Is(err, repositories.ErrUserNotFound) {
+				http.Error(w, "user not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to update role: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"id": userID, "role": input.Role})
+	}
+}
+
+// GetUser returns a single user: GET /users/{userID}
+func GetUser(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 2 || parts[0] != "users" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), parts[1])
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+// UpdateUser changes the name and/or role of a user: PATCH /users/{userID}.
+// Fields left out of the body are kept.
+func UpdateUser(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 2 || parts[0] != "users" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		userID := parts[1]
+
+		var input struct {
+			models.UserProfileUpdate
+			Role *string `json:"role"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if err := validateProfileUpdate(&input.UserProfileUpdate); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if input.Role != nil {
+			if !models.ValidRole(*input.Role) {
+				http.Error(w, "Role must be admin or member", http.StatusBadRequest)
+				return
+			}
+			if claims, ok := claimsFromContext(r.Context()); ok && claims.Subject == userID {
+				http.Error(w, "Cannot change your own role", http.StatusBadRequest)
+				return
+			}
+		}
+
+		err := repositories.UpdateUserProfile(r.Context(), db.(*sql.DB), userID, input.UserProfileUpdate)
+		if err == nil && input.Role != nil {
+			err = repositories.UpdateUserRole(r.Context(), db.(*sql.DB), userID, *input.Role)
+		}
+		if err != nil {
+			if errors.Is(err, repositories.ErrUserNotFound) {
+				http.Error(w, "user not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to update user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			http.Error(w, "Failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+// DeleteUser deletes a user and everything they own: DELETE /users/{userID}
+func DeleteUser(db DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 2 || parts[0] != "users" {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		userID := parts[1]
+
+		if claims, ok := claimsFromContext(r.Context()); ok && claims.Subject == userID {
+			http.Error(w, "Cannot delete yourself", http.StatusBadRequest)
+			return
+		}
+
+		// Refresh tokens and API keys are removed along with the user
+		favourites, err := repositories.DeleteUser(r.Context(), db.(*sql.DB), userID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrUserNotFound) {
+				http.Error(w, "user not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to delete user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"id": userID, "favourites_removed": favourites})
+	}
+}
+
+// DisableUser blocks a user from logging in and revokes their tokens:
+// POST /users/{userID}/disable
+func DisableUser(db DB) http.HandlerFunc {
+	return setUserDisabled(db, true)
+}
+
+// EnableUser lets a disabled user log in again: POST /users/{userID}/enable
+func EnableUser(db DB) http.HandlerFunc {
+	return setUserDisabled(db, false)
+}
+
+func setUserDisabled(db DB, disabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !requireScope(w, r, models.ScopeUsersAdmin) {
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 3 || parts[0] != "users" || (parts[2] != "disable" && parts[2] != "enable") {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		userID := parts[1]
+
+		if claims, ok := claimsFromContext(r.Context()); ok && claims.Subject == userID {
+			http.Error(w, "Cannot change your own status", http.StatusBadRequest)
+			return
+		}
+
+		err := repositories.SetUserDisabled(r.Context(), db.(*sql.DB), userID, disabled)
+		if err != nil {
+			if errors.Is(err, repositories.ErrUserNotFound) {
+				http.Error(w, "user not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to update user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if disabled {
+			if err := revokeAllUserTokens(r.Context(), db.(*sql.DB), userID); err != nil {
+				http.Error(w, "Failed to revoke tokens: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"id": userID, "disabled": disabled})
+	}
+}
+
+// Login authenticates user and returns a JWT access token and refresh token
 func Login(db DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -74,8 +430,18 @@ func Login(db DB) http.HandlerFunc {
 		}
 
 		var creds struct {
+			// ID is the user ID or email address
 			ID       string `json:"id"`
+			Email    string `json:"email"`
 			Password string `json:"password"`
+			// Scope optionally limits the tokens to a space-separated
+			// subset of the scopes allowed for the user's role.
+			Scope string `json:"scope"`
+			// Session "cookie" sets the tokens as HttpOnly cookies for
+			// browsers instead of returning them.
+			Session string `json:"session"`
+			// Restore cancels the pending deletion of the account.
+			Restore bool `json:"restore"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
@@ -83,45 +449,127 @@ func Login(db DB) http.HandlerFunc {
 			return
 		}
 
+		if creds.ID == "" {
+			creds.ID = creds.Email
+		}
+
 		if creds.ID == "" || creds.Password == "" {
 			http.Error(w, "ID and password required", http.StatusBadRequest)
 			return
 		}
 
+		if !validSessionMode(creds.Session) {
+			http.Error(w, "Invalid session mode", http.StatusBadRequest)
+			return
+		}
+
+		// IDs cannot contain @, so this is an email. Resolve it to the ID so
+		// both count towards the same lockout.
+		if strings.Contains(creds.ID, "@") {
+			user, err := repositories.GetUserByEmail(r.Context(), db.(*sql.DB), creds.ID)
+			if err != nil {
+				http.Error(w, "Failed to verify credentials", http.StatusInternalServerError)
+				return
+			}
+			if user != nil {
+				creds.ID = user.ID
+			}
+		}
+
+		// Refuse locked out accounts and IPs before doing any password work
+		if !checkLoginLockout(w, r, db.(*sql.DB), creds.ID) {
+			return
+		}
+
 		// Verify credentials
 		user, err := repositories.GetUserByID(r.Context(), db.(*sql.DB), creds.ID)
-		if err != nil || user == nil {
+		if err != nil {
+			http.Error(w, "Failed to verify credentials", http.StatusInternalServerError)
+			return
+		}
+
+		match, needsRehash := false, false
+		// Users provisioned through single sign-on have no password
+		if user != nil && user.PasswordHash != "" {
+			match, needsRehash, err = passwordHasher.Verify(creds.Password, user.PasswordHash)
+			if err != nil {
+				println("Error verifying password for", user.ID+":", err.Error())
+			}
+		}
+
+		if !match {
+			if err := recordLoginFailure(r.Context(), db.(*sql.DB), clientIP(r), creds.ID); err != nil {
+				println("Error recording login failure:", err.Error())
+			}
 			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 			return
 		}
 
-		// Verify password hash
-		if user.PasswordHash == "" {
-			http.Error(w, "User not properly configured", http.StatusUnauthorized)
+		// Only tell the account is disabled to someone knowing the password
+		if user.DisabledAt != nil {
+			http.Error(w, "Account is disabled", http.StatusForbidden)
+			return
+		}
+		if !checkAccountDeleted(w, user, creds.Restore) {
+			return
+		}
+
+		scope, err := grantScope(creds.Scope, user.Role)
+		if err != nil {
+			http.Error(w, "Invalid scope: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)); err != nil {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		// Transparently upgrade hashes made with an old algorithm or cost
+		if needsRehash {
+			if hash, err := passwordHasher.Hash(creds.Password); err != nil {
+				println("Error rehashing password:", err.Error())
+			} else if err := repositories.UpdateUserPassword(r.Context(), db.(*sql.DB), user.ID, hash); err != nil {
+				println("Error storing rehashed password:", err.Error())
+			}
+		}
+
+		// Accounts with two-factor authentication get a challenge for
+		// LoginTwoFactor instead of tokens. Failures are only cleared once
+		// the second factor has been verified too.
+		enrollment, err := repositories.GetTOTPEnrollment(r.Context(), db.(*sql.DB), user.ID)
+		if err != nil {
+			http.Error(w, "Failed to fetch two-factor status: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if enrollment != nil && enrollment.EnabledAt != nil {
+			challenge, err := issueTwoFactorChallenge(user, scope, creds.Restore)
+			if err != nil {
+				http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+				return
+			}
 
-		// Generate JWT token
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-			Subject:   user.ID,
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		})
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(challenge)
+			return
+		}
+
+		if err := restoreAccount(r, db.(*sql.DB), user); err != nil {
+			http.Error(w, "Failed to restore account: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := repositories.ClearLoginFailures(r.Context(), db.(*sql.DB), accountLockoutKey(user.ID)); err != nil {
+			println("Error clearing login failures:", err.Error())
+		}
+		if err := recordLogin(r, db.(*sql.DB), user.ID, models.LoginMethodPassword); err != nil {
+			println("Error recording login:", err.Error())
+		}
 
-		tokenString, err := token.SignedString(jwtSecret)
+		// Generate JWT access token and a new refresh token family
+		resp, err := issueTokenPair(r.Context(), db.(*sql.DB), user, "", scope, nil)
 		if err != nil {
 			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"token": tokenString})
+		writeTokenResponse(w, resp, creds.Session)
 	}
 }
 
@@ -134,9 +582,15 @@ func Register(db DB) http.HandlerFunc {
 		}
 
 		var input struct {
-			ID       string `json:"id"`
-			Name     string `json:"name"`
-			Password string `json:"password"`
+			ID          string `json:"id"`
+			Name        string `json:"name"`
+			Password    string `json:"password"`
+			Email       string `json:"email"`
+			DisplayName string `json:"display_name"`
+			AvatarURL   string `json:"avatar_url"`
+			Locale      string `json:"locale"`
+
+			InvitationCode string `json:"invitation_code"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -144,18 +598,45 @@ func Register(db DB) http.HandlerFunc {
 			return
 		}
 
-		if input.ID == "" || input.Name == "" || input.Password == "" {
-			http.Error(w, "ID, name, and password required", http.StatusBadRequest)
+		if !publicRegistration && input.InvitationCode == "" {
+			http.Error(w, "Registration is by invitation only", http.StatusForbidden)
+			return
+		}
+
+		input.Email = strings.TrimSpace(input.Email)
+		if input.ID == "" || input.Name == "" || input.Password == "" || input.Email == "" {
+			http.Error(w, "ID, name, email, and password required", http.StatusBadRequest)
 			return
 		}
 
-		if len(input.Password) < 6 {
-			http.Error(w, "Password must be at least 6 characters", http.StatusBadRequest)
+		if strings.Contains(input.ID, "@") {
+			http.Error(w, "ID cannot contain @", http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Password) < minPasswordLength {
+			http.Error(w, fmt.Sprintf("Password must be at least %d characters", minPasswordLength), http.StatusBadRequest)
+			return
+		}
+
+		input.DisplayName = strings.TrimSpace(input.DisplayName)
+		if err := validateProfile(input.Email, input.DisplayName, input.AvatarURL, input.Locale); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		inUse, err := emailInUse(r, db.(*sql.DB), input.Email)
+		if err != nil {
+			http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if inUse {
+			http.Error(w, "Email already in use", http.StatusConflict)
 			return
 		}
 
 		// Hash password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+		hashedPassword, err := passwordHasher.Hash(input.Password)
 		if err != nil {
 			http.Error(w, "Failed to process password", http.StatusInternalServerError)
 			return
@@ -164,17 +645,62 @@ func Register(db DB) http.HandlerFunc {
 		user := models.User{
 			ID:           input.ID,
 			Name:         input.Name,
-			PasswordHash: string(hashedPassword),
+			Email:        input.Email,
+			DisplayName:  input.DisplayName,
+			AvatarURL:    input.AvatarURL,
+			Locale:       input.Locale,
+			Role:         models.RoleMember,
+			PasswordHash: hashedPassword,
+		}
+
+		if input.InvitationCode != "" {
+			inv, err := repositories.CreateUserWithInvitation(r.Context(), db.(*sql.DB), user, hashOpaqueToken(input.InvitationCode))
+			if errors.Is(err, repositories.ErrUserExists) {
+				http.Error(w, errUserIDTaken.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if inv == nil {
+				http.Error(w, "Invalid or expired invitation", http.StatusBadRequest)
+				return
+			}
+
+			// An invitation sent to this address already proves ownership
+			verified := inv.Email != ""
+			if !verified {
+				if err := sendEmailVerification(r, db.(*sql.DB), &user); err != nil {
+					println("Error sending verification email:", err.Error())
+				}
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]any{"id": user.ID, "name": input.Name, "email": input.Email, "email_verified": verified, "role": inv.Role})
+			return
 		}
 
 		userID, err := repositories.CreateUser(r.Context(), db.(*sql.DB), user)
+		if errors.Is(err, repositories.ErrUserExists) {
+			http.Error(w, errUserIDTaken.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		// The account works right away, but favourites stay read-only
+		// until the address is confirmed
+		user.ID = userID
+		if err := sendEmailVerification(r, db.(*sql.DB), &user); err != nil {
+			println("Error sending verification email:", err.Error())
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]string{"id": userID, "name": input.Name})
+		json.NewEncoder(w).Encode(map[string]any{"id": userID, "name": input.Name, "email": input.Email, "email_verified": false, "role": models.RoleMember})
 	}
 }
@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"platform-go-challenge/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 func TestAddUser_MissingName(t *testing.T) {
@@ -148,3 +151,396 @@ func TestAddUser_POST_MethodCheck(t *testing.T) {
 		t.Errorf("expected validation error, got status %d", rec.Code)
 	}
 }
+
+func TestAddUser_InvalidRole(t *testing.T) {
+	body := `{"id":"u3","name":"Carol","role":"owner"}`
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	var mockDB *sql.DB
+	AddUser(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateUserRole_InvalidRole(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/users/u2/role", strings.NewReader(`{"role":"owner"}`))
+	rec := httptest.NewRecorder()
+
+	var mockDB *sql.DB
+	UpdateUserRole(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateUserRole_OwnRole(t *testing.T) {
+	req := withClaims(httptest.NewRequest(http.MethodPut, "/users/u1/role", strings.NewReader(`{"role":"member"}`)), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	var mockDB *sql.DB
+	UpdateUserRole(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestUpdateUserRole_UserNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("missing", models.RoleAdmin, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := withClaims(httptest.NewRequest(http.MethodPut, "/users/missing/role", strings.NewReader(`{"role":"admin"}`)), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	UpdateUserRole(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateUserRole_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u2", models.RoleAdmin, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := withClaims(httptest.NewRequest(http.MethodPut, "/users/u2/role", strings.NewReader(`{"role":"admin"}`)), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	UpdateUserRole(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var resp map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["role"] != models.RoleAdmin {
+		t.Fatalf("role = %q, want %q", resp["role"], models.RoleAdmin)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetUser_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("missing", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/users/missing", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	UserRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateUser_ChangesNameAndRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u2", "Robert", nil, nil, nil, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u2", models.RoleAdmin, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Robert", "hash", models.RoleAdmin)...))
+
+	body := `{"name":"Robert","role":"admin"}`
+	req := withClaims(httptest.NewRequest(http.MethodPatch, "/users/u2", strings.NewReader(body)), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	UserRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteUser_ReportsRemovedFavourites(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectUserTx(mock, "u1", models.RoleAdmin)
+	mock.ExpectExec("DELETE FROM favourites").
+		WithArgs("u2", "default").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM users").
+		WithArgs("u2", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	req := withClaims(httptest.NewRequest(http.MethodDelete, "/users/u2", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	UserRouter(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp struct {
+		ID                string `json:"id"`
+		FavouritesRemoved int    `json:"favourites_removed"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.ID != "u2" || resp.FavouritesRemoved != 3 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteUser_Self(t *testing.T) {
+	req := withClaims(httptest.NewRequest(http.MethodDelete, "/users/u1", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	var mockDB *sql.DB
+	UserRouter(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestDisableUser_RevokesTokens(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u2", true, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_token_revocations").
+		WithArgs("u2", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens").
+		WithArgs("u2", "default").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/users/u2/disable", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	DisableUser(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDisableUser_Self(t *testing.T) {
+	req := withClaims(httptest.NewRequest(http.MethodPost, "/users/u1/disable", nil), "u1", models.RoleAdmin)
+	rec := httptest.NewRecorder()
+
+	var mockDB *sql.DB
+	DisableUser(mockDB).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestLogin_DisabledUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := passwordHasher.Hash("bob123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	expectNoLockout(mock, "u2")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(disabledUserRow("u2", "Bob", hash, models.RoleMember)...))
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"id":"u2","password":"bob123"}`))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusForbidden, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRegister_InvalidProfile(t *testing.T) {
+	cases := map[string]string{
+		"email":  `{"id":"u3","name":"Carol","password":"carol123","email":"carol"}`,
+		"avatar": `{"id":"u3","name":"Carol","password":"carol123","email":"carol@example.com","avatar_url":"javascript:alert(1)"}`,
+		"locale": `{"id":"u3","name":"Carol","password":"carol123","email":"carol@example.com","locale":"english!"}`,
+		"id":     `{"id":"carol@example.com","name":"Carol","password":"carol123","email":"carol@example.com"}`,
+	}
+
+	for name, body := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			var mockDB *sql.DB
+			Register(mockDB).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestRegister_EmailInUse(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("WHERE lower\\(email\\)").
+		WithArgs("Bob@Example.com", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).
+			AddRow(userRow("u2", "Bob", "hash", models.RoleMember)...))
+
+	body := `{"id":"u3","name":"Carol","password":"carol123","email":"Bob@Example.com"}`
+	rec := httptest.NewRecorder()
+	Register(db).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRegister_IDTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("WHERE lower\\(email\\)").
+		WithArgs("carol@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns))
+	mock.ExpectQuery("INSERT INTO users").
+		WithArgs("u1", "Carol", sqlmock.AnyArg(), models.RoleMember, "carol@example.com", "", "", "", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	body := `{"id":"u1","name":"Carol","password":"carol123","email":"carol@example.com"}`
+	rec := httptest.NewRecorder()
+	Register(db).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusConflict, rec.Body.String())
+	}
+	if got := strings.TrimSpace(rec.Body.String()); got != errUserIDTaken.Error() {
+		t.Fatalf("body = %q, want %q", got, errUserIDTaken.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLogin_ByEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	hash, err := passwordHasher.Hash("bob123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	row := userRow("u2", "Bob", hash, models.RoleMember)
+	row[5] = "bob@example.com"
+
+	mock.ExpectQuery("WHERE lower\\(email\\)").
+		WithArgs("BOB@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(row...))
+	// Lockout and everything after use the resolved ID
+	expectNoLockout(mock, "u2")
+	mock.ExpectQuery("SELECT id, name, password_hash").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows(userColumns).AddRow(row...))
+	expectNoTwoFactor(mock, "u2")
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectRecordLogin(mock, "u2")
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs(sqlmock.AnyArg(), "u2", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"BOB@example.com","password":"bob123"}`))
+	rec := httptest.NewRecorder()
+
+	Login(db).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
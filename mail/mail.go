@@ -0,0 +1,59 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages to users.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FileOutbox is a Mailer that writes each message to its own .eml file in
+// Dir instead of sending it, for local development and tests.
+type FileOutbox struct {
+	Dir string
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._@-]+`)
+
+var sequence atomic.Uint64
+
+func (o *FileOutbox) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(o.Dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s-%04d-%s.eml",
+		now.Format("20060102T150405.000000000"),
+		sequence.Add(1)%10000,
+		unsafeFileChars.ReplaceAllString(msg.To, "_"),
+	)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(msg.Body)
+
+	return os.WriteFile(filepath.Join(o.Dir, name), []byte(b.String()), 0o600)
+}
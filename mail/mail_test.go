@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileOutbox_Send(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	outbox := &FileOutbox{Dir: dir}
+
+	msg := Message{To: "u1", Subject: "Hello", Body: "Line one\nLine two"}
+	if err := outbox.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+	if err := outbox.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatalf("glob error: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(files))
+	}
+
+	raw, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	for _, want := range []string{"To: u1", "Subject: Hello", "Line two"} {
+		if !strings.Contains(string(raw), want) {
+			t.Fatalf("message missing %q:\n%s", want, raw)
+		}
+	}
+}
+
+func TestFileOutbox_SanitizesRecipient(t *testing.T) {
+	dir := t.TempDir()
+	outbox := &FileOutbox{Dir: dir}
+
+	if err := outbox.Send(context.Background(), Message{To: "../../etc/passwd"}); err != nil {
+		t.Fatalf("Send error: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected message inside outbox dir, got %v", files)
+	}
+}
+
+func TestFileOutbox_CancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	outbox := &FileOutbox{Dir: t.TempDir()}
+	if err := outbox.Send(ctx, Message{To: "u1"}); err == nil {
+		t.Fatal("expected error for cancelled context")
+	}
+}
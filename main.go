@@ -11,6 +11,9 @@ import (
 	"os/signal"
 	"platform-go-challenge/db"
 	"platform-go-challenge/handlers"
+	"platform-go-challenge/models"
+	"platform-go-challenge/oidc"
+	"strings"
 	"syscall"
 	"time"
 
@@ -31,6 +34,13 @@ import (
 // @in header
 // @name Authorization
 // @description Enter the token with the 'Bearer ' prefix, e.g. 'Bearer abc123'
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+// @securityDefinitions.apikey CSRFToken
+// @in header
+// @name X-CSRF-Token
+// @description The csrf_token returned by a cookie session login, required on writes
 
 func initDatabase() (*sql.DB, error) {
 	dsn := os.Getenv("DATABASE_URL")
@@ -55,13 +65,50 @@ func initServer(database *sql.DB) *http.Server {
 	// Health
 	mux.HandleFunc("/health", handlers.HealthCheck)
 
+	// Public keys for verifying access tokens
+	mux.HandleFunc("/.well-known/jwks.json", handlers.JWKS)
+
 	// Public routes
 	mux.HandleFunc("/login", handlers.Login(database))
+	mux.HandleFunc("/login/2fa", handlers.LoginTwoFactor(database))
 	mux.HandleFunc("/register", handlers.Register(database))
+	mux.HandleFunc("/token/refresh", handlers.RefreshToken(database))
+	mux.HandleFunc("/logout", handlers.Logout(database))
+	mux.HandleFunc("/password/forgot", handlers.ForgotPassword(database))
+	mux.HandleFunc("/password/reset", handlers.ResetPassword(database))
+	mux.HandleFunc("/email/verify", handlers.VerifyEmail(database))
+	mux.HandleFunc("/auth/oidc/login", handlers.OIDCLogin(database))
+	mux.HandleFunc("/auth/oidc/callback", handlers.OIDCCallback(database))
 
 	// Protected routes
-	mux.HandleFunc("/users", handlers.AuthMiddleware(handlers.UserRouter(database)))
-	mux.Handle("/users/", handlers.AuthMiddleware(handlers.FavouritesRouter(database)))
+	// Routes wrapped in RequireRole are limited to the listed roles,
+	// everything else is open to any authenticated user.
+	mux.HandleFunc("/users", handlers.AuthMiddleware(handlers.RequireRole(handlers.UserRouter(database), models.RoleAdmin)))
+	mux.HandleFunc("PUT /users/{id}/role", handlers.AuthMiddleware(handlers.RequireRole(handlers.UpdateUserRole(database), models.RoleAdmin)))
+	mux.HandleFunc("POST /users/{id}/unlock", handlers.AuthMiddleware(handlers.RequireRole(handlers.UnlockUser(database), models.RoleAdmin)))
+	mux.HandleFunc("/me", handlers.AuthMiddleware(handlers.MeRouter(database)))
+	mux.HandleFunc("/me/favourites", handlers.AuthMiddleware(handlers.MeFavouritesRouter(database)))
+	mux.Handle("/me/favourites/", handlers.AuthMiddleware(handlers.MeFavouritesRouter(database)))
+	mux.HandleFunc("/me/password", handlers.AuthMiddleware(handlers.ChangePassword(database)))
+	mux.HandleFunc("/me/email/verification", handlers.AuthMiddleware(handlers.ResendEmailVerification(database)))
+	mux.HandleFunc("/me/2fa/enroll", handlers.AuthMiddleware(handlers.EnrollTwoFactor(database)))
+	mux.HandleFunc("/me/2fa/verify", handlers.AuthMiddleware(handlers.VerifyTwoFactor(database)))
+	mux.HandleFunc("/me/org", handlers.AuthMiddleware(handlers.SwitchOrganization(database)))
+	mux.HandleFunc("/tokens/revoke", handlers.AuthMiddleware(handlers.RequireRole(handlers.RevokeTokens(database), models.RoleAdmin)))
+	mux.HandleFunc("/users/{id}/api-keys", handlers.AuthMiddleware(handlers.APIKeysRouter(database)))
+	mux.HandleFunc("/users/{id}/api-keys/{keyId}", handlers.AuthMiddleware(handlers.APIKeysRouter(database)))
+	mux.HandleFunc("/users/{id}", handlers.AuthMiddleware(handlers.RequireRole(handlers.UserRouter(database), models.RoleAdmin)))
+	mux.HandleFunc("POST /users/{id}/disable", handlers.AuthMiddleware(handlers.RequireRole(handlers.DisableUser(database), models.RoleAdmin)))
+	mux.HandleFunc("POST /users/{id}/impersonate", handlers.AuthMiddleware(handlers.RequireRole(handlers.ImpersonateUser(database), models.RoleAdmin)))
+	mux.HandleFunc("POST /users/{id}/restore", handlers.AuthMiddleware(handlers.RequireRole(handlers.RestoreUser(database), models.RoleAdmin)))
+	mux.HandleFunc("POST /users/{id}/enable", handlers.AuthMiddleware(handlers.RequireRole(handlers.EnableUser(database), models.RoleAdmin)))
+	mux.HandleFunc("/invitations", handlers.AuthMiddleware(handlers.RequireRole(handlers.InvitationsRouter(database), models.RoleAdmin)))
+	mux.HandleFunc("/invitations/{id}", handlers.AuthMiddleware(handlers.RequireRole(handlers.InvitationsRouter(database), models.RoleAdmin)))
+	mux.HandleFunc("/orgs", handlers.AuthMiddleware(handlers.OrganizationsRouter(database)))
+	mux.Handle("/orgs/", handlers.AuthMiddleware(handlers.OrganizationsRouter(database)))
+	mux.HandleFunc("/users/{id}/export", handlers.AuthMiddleware(handlers.ExportUser(database)))
+	mux.HandleFunc("/users/{id}/favourites", handlers.AuthMiddleware(handlers.FavouritesRouter(database)))
+	mux.HandleFunc("/users/{id}/favourites/{assetId}", handlers.AuthMiddleware(handlers.FavouritesRouter(database)))
 	mux.HandleFunc("/assets", handlers.AuthMiddleware(handlers.AssetsRouter(database)))
 	mux.Handle("/assets/", handlers.AuthMiddleware(handlers.AssetsRouter(database)))
 
@@ -72,7 +119,7 @@ func initServer(database *sql.DB) *http.Server {
 
 	return &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: handlers.TenantMiddleware(mux),
 	}
 }
 
@@ -89,6 +136,39 @@ func main() {
 	}
 	defer database.Close()
 
+	// ---- background jobs ----
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	if err := handlers.EnableTokenDenylist(jobsCtx, database, 30*time.Second); err != nil {
+		log.Fatalf("token denylist initialization failed: %v", err)
+	}
+
+	handlers.EnableAPIKeys(database)
+	handlers.EnableAuditLog(database)
+	handlers.EnableAccountPurge(jobsCtx, database, time.Hour)
+	handlers.EnableLoginFailurePurge(jobsCtx, database, time.Hour)
+
+	// ---- single sign-on ----
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		scopes := os.Getenv("OIDC_SCOPES")
+		if scopes == "" {
+			scopes = "openid profile email"
+		}
+
+		provider, err := oidc.Discover(context.Background(), oidc.Config{
+			IssuerURL:    issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       strings.Fields(scopes),
+		})
+		if err != nil {
+			log.Fatalf("OIDC provider initialization failed: %v", err)
+		}
+		handlers.EnableOIDC(provider)
+	}
+
 	// ---- server ----
 	server := initServer(database)
 
@@ -8,6 +8,10 @@ import (
 	"strings"
 	"testing"
 
+	"platform-go-challenge/handlers"
+	"platform-go-challenge/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
 	_ "github.com/lib/pq"
 )
 
@@ -168,3 +172,125 @@ func TestInitServerHandlerNotNil(t *testing.T) {
 		// This is acceptable - just checking the handler works
 	}
 }
+
+func TestInitServerRolePolicies(t *testing.T) {
+	// No expectations are set: requests that get past the role check fail
+	// at the database, which is enough to tell them apart from a 403.
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	server := initServer(db)
+
+	tokens := map[string]string{}
+	for _, u := range []models.User{
+		{ID: "admin", Role: models.RoleAdmin},
+		{ID: "member", Role: models.RoleMember},
+	} {
+		token, err := handlers.IssueToken(&u)
+		if err != nil {
+			t.Fatalf("IssueToken error: %v", err)
+		}
+		tokens[u.Role] = token
+	}
+
+	tests := []struct {
+		method    string
+		path      string
+		body      string
+		role      string
+		forbidden bool
+	}{
+		{http.MethodGet, "/users", "", models.RoleAdmin, false},
+		{http.MethodGet, "/users", "", models.RoleMember, true},
+		{http.MethodPost, "/users", `{"id":"u3","name":"Carol"}`, models.RoleAdmin, false},
+		{http.MethodPost, "/users", `{"id":"u3","name":"Carol"}`, models.RoleMember, true},
+		{http.MethodPut, "/users/u2/role", `{"role":"admin"}`, models.RoleAdmin, false},
+		{http.MethodPut, "/users/u2/role", `{"role":"admin"}`, models.RoleMember, true},
+		{http.MethodPost, "/users/u2/unlock", "", models.RoleAdmin, false},
+		{http.MethodPost, "/users/u2/unlock", "", models.RoleMember, true},
+		{http.MethodGet, "/users/u2", "", models.RoleAdmin, false},
+		{http.MethodGet, "/users/u2", "", models.RoleMember, true},
+		{http.MethodPatch, "/users/u2", `{"name":"Bob"}`, models.RoleAdmin, false},
+		{http.MethodPatch, "/users/u2", `{"name":"Bob"}`, models.RoleMember, true},
+		{http.MethodDelete, "/users/u2", "", models.RoleAdmin, false},
+		{http.MethodDelete, "/users/member", "", models.RoleMember, true},
+		{http.MethodPost, "/users/u2/disable", "", models.RoleAdmin, false},
+		{http.MethodPost, "/users/u2/disable", "", models.RoleMember, true},
+		{http.MethodPost, "/users/u2/enable", "", models.RoleAdmin, false},
+		{http.MethodPost, "/users/u2/enable", "", models.RoleMember, true},
+		{http.MethodPost, "/users/u2/restore", "", models.RoleAdmin, false},
+		{http.MethodPost, "/users/u2/restore", "", models.RoleMember, true},
+		{http.MethodPost, "/users/u2/impersonate", "", models.RoleAdmin, false},
+		{http.MethodPost, "/users/u2/impersonate", "", models.RoleMember, true},
+		{http.MethodGet, "/invitations", "", models.RoleAdmin, false},
+		{http.MethodGet, "/invitations", "", models.RoleMember, true},
+		{http.MethodDelete, "/invitations/i1", "", models.RoleMember, true},
+		{http.MethodPost, "/tokens/revoke", `{"jti":"x"}`, models.RoleAdmin, false},
+		{http.MethodPost, "/tokens/revoke", `{"jti":"x"}`, models.RoleMember, true},
+		{http.MethodPost, "/assets", `{"type":"chart","title":"t"}`, models.RoleAdmin, false},
+		{http.MethodPost, "/assets", `{"type":"chart","title":"t"}`, models.RoleMember, true},
+		{http.MethodPost, "/assets/", `{"type":"chart","title":"t"}`, models.RoleAdmin, false},
+		{http.MethodPost, "/assets/", `{"type":"chart","title":"t"}`, models.RoleMember, true},
+		{http.MethodGet, "/assets", "", models.RoleMember, false},
+		{http.MethodGet, "/assets/a1", "", models.RoleMember, false},
+		{http.MethodGet, "/users/member/favourites", "", models.RoleMember, false},
+		{http.MethodGet, "/users/admin/favourites", "", models.RoleMember, true},
+		{http.MethodGet, "/users/member/favourites", "", models.RoleAdmin, false},
+		{http.MethodGet, "/users/member/api-keys", "", models.RoleMember, false},
+		{http.MethodGet, "/users/admin/api-keys", "", models.RoleMember, true},
+		{http.MethodDelete, "/users/member/api-keys/k1", "", models.RoleAdmin, false},
+		{http.MethodGet, "/users/member/export", "", models.RoleMember, false},
+		{http.MethodGet, "/users/admin/export", "", models.RoleMember, true},
+		{http.MethodGet, "/users/member/export", "", models.RoleAdmin, false},
+		{http.MethodGet, "/me", "", models.RoleMember, false},
+		{http.MethodPost, "/me/email/verification", "", models.RoleMember, false},
+		{http.MethodGet, "/me/favourites", "", models.RoleMember, false},
+		{http.MethodDelete, "/me/favourites/a1", "", models.RoleMember, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role+" "+tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tokens[tt.role])
+			rec := httptest.NewRecorder()
+
+			server.Handler.ServeHTTP(rec, req)
+
+			if got := rec.Code == http.StatusForbidden; got != tt.forbidden {
+				t.Fatalf("status = %d, forbidden = %v, want forbidden = %v", rec.Code, got, tt.forbidden)
+			}
+		})
+	}
+}
+
+func TestInitServerCreateAssetRequiresAdmin(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	server := initServer(db)
+
+	// A member carrying assets:write, e.g. an admin demoted after the token
+	// was issued, is still refused on either path
+	token, err := handlers.IssueToken(&models.User{ID: "member", Role: models.RoleMember}, models.ScopeAssetsWrite)
+	if err != nil {
+		t.Fatalf("IssueToken error: %v", err)
+	}
+
+	for _, path := range []string{"/assets", "/assets/"} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"type":"chart","title":"t"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+
+		server.Handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("POST %s status = %d, want %d", path, rec.Code, http.StatusForbidden)
+		}
+	}
+}
@@ -13,10 +13,28 @@ const (
 	AssetAudience AssetType = "audience"
 )
 
+// AssetVisibility controls who can see and favourite an asset. Admins see
+// every asset.
+type AssetVisibility string
+
+const (
+	VisibilityPrivate AssetVisibility = "private" // Only the creator
+	VisibilityOrg     AssetVisibility = "org"     // Members of the organization owning the asset
+	VisibilityPublic  AssetVisibility = "public"  // Every user
+)
+
+// ValidAssetVisibility reports whether v is one of the known visibilities.
+func ValidAssetVisibility(v AssetVisibility) bool {
+	return v == VisibilityPrivate || v == VisibilityOrg || v == VisibilityPublic
+}
+
 type Asset struct {
-	ID        string          `db:"id"`
-	Type      AssetType       `db:"type"`
-	Title     *string         `db:"title"`
-	Data      json.RawMessage `db:"data"` // JSONB
-	CreatedAt time.Time       `db:"created_at"`
+	ID         string          `db:"id"`
+	Type       AssetType       `db:"type"`
+	Title      *string         `db:"title"`
+	Data       json.RawMessage `db:"data"` // JSONB
+	CreatedAt  time.Time       `db:"created_at"`
+	OrgID      *string         `db:"org_id"`     // Organization owning the asset, nil for shared assets
+	CreatedBy  *string         `db:"created_by"` // User who created the asset, nil for seeded assets
+	Visibility AssetVisibility `db:"visibility"`
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Audit actions.
+const (
+	AuditImpersonationStart   = "impersonation.start"
+	AuditImpersonationRequest = "impersonation.request"
+	AuditUserExport           = "user.export"
+)
+
+// AuditEvent records an action ActorID took as, or on behalf of, UserID.
+type AuditEvent struct {
+	ID        int64     `json:"id" db:"id"`
+	ActorID   string    `json:"actor_id" db:"actor_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Action    string    `json:"action" db:"action"`
+	Detail    string    `json:"detail,omitempty" db:"detail"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type UserIdentity struct {
+	Issuer      string     `db:"issuer"`
+	Subject     string     `db:"subject"`
+	UserID      string     `db:"user_id"`
+	Email       string     `db:"email"`
+	CreatedAt   time.Time  `db:"created_at"`
+	LastLoginAt *time.Time `db:"last_login_at"`
+}
+
+type OIDCLoginState struct {
+	StateHash    string    `db:"state_hash"`
+	Nonce        string    `db:"nonce"`
+	CodeVerifier string    `db:"code_verifier"`
+	LinkUserID   string    `db:"link_user_id"`
+	SessionMode  string    `db:"session_mode"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
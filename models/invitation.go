@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Invitation lets someone register when public registration is disabled.
+type Invitation struct {
+	ID        string     `json:"id" db:"id"`
+	CodeHash  string     `json:"-" db:"code_hash"` // Never expose to client
+	Email     string     `json:"email,omitempty" db:"email"`
+	Role      string     `json:"role" db:"role"`
+	CreatedBy string     `json:"created_by,omitempty" db:"created_by"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	UsedBy    string     `json:"used_by,omitempty" db:"used_by"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
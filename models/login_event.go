@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Login methods.
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodTwoFactor = "2fa"
+	LoginMethodOIDC      = "oidc"
+)
+
+// LoginEvent is a successful login in a user's login history.
+type LoginEvent struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Method    string    `json:"method" db:"method"`
+	IPAddress string    `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent string    `json:"user_agent,omitempty" db:"user_agent"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
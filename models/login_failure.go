@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type LoginFailure struct {
+	Key           string     `db:"key"`
+	Failures      int        `db:"failures"`
+	LastFailureAt time.Time  `db:"last_failure_at"`
+	LockedUntil   *time.Time `db:"locked_until"`
+}
@@ -158,3 +158,30 @@ func TestFavouriteAssetModel(t *testing.T) {
 func ptrString(s string) *string {
 	return &s
 }
+
+func TestUserProfileValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid func(string) bool
+		value string
+		want  bool
+	}{
+		{"email", ValidEmail, "alice@example.com", true},
+		{"email with display name", ValidEmail, "Alice <alice@example.com>", false},
+		{"email without domain", ValidEmail, "alice", false},
+		{"avatar https", ValidAvatarURL, "https://cdn.example.com/a.png", true},
+		{"avatar relative", ValidAvatarURL, "/a.png", false},
+		{"avatar javascript", ValidAvatarURL, "javascript:alert(1)", false},
+		{"locale language", ValidLocale, "en", true},
+		{"locale region", ValidLocale, "pt-BR", true},
+		{"locale garbage", ValidLocale, "english!", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.valid(tt.value); got != tt.want {
+				t.Errorf("valid(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"regexp"
+	"time"
+)
+
+// Roles of a user within an organization, from most to least privileged.
+// Owners and admins manage the organization and its members; only owners
+// can make others owners.
+const (
+	OrgRoleOwner  = "owner"
+	OrgRoleAdmin  = "admin"
+	OrgRoleMember = "member"
+)
+
+type Organization struct {
+	ID        string    `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Role      string    `json:"role,omitempty"` // Role of the caller, when listing their organizations
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type OrganizationMember struct {
+	OrgID     string    `json:"org_id" db:"org_id"`
+	UserID    string    `json:"user_id" db:"user_id"`
+	Name      string    `json:"name,omitempty"` // Name of the user
+	Role      string    `json:"role" db:"role"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+var orgIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// ValidOrgID reports whether id is a lowercase slug such as "acme-corp".
+func ValidOrgID(id string) bool {
+	return orgIDPattern.MatchString(id)
+}
+
+// ValidOrgRole reports whether role is one of the known organization roles.
+func ValidOrgRole(role string) bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleMember
+}
+
+// CanManage reports whether the member may change the organization and its
+// memberships.
+func (m *OrganizationMember) CanManage() bool {
+	return m.Role == OrgRoleOwner || m.Role == OrgRoleAdmin
+}
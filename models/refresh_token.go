@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	TokenHash string     `db:"token_hash"`
+	UserID    string     `db:"user_id"`
+	FamilyID  string     `db:"family_id"`
+	Scope     string     `db:"scope"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	RotatedAt *time.Time `db:"rotated_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+	CreatedAt time.Time  `db:"created_at"`
+	OrgID     string     `db:"org_id"` // Active organization, empty for none
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type RevokedToken struct {
+	JTI       string    `db:"jti"`
+	UserID    *string   `db:"user_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+	RevokedAt time.Time `db:"revoked_at"`
+}
+
+type UserTokenRevocation struct {
+	UserID        string    `db:"user_id"`
+	RevokedBefore time.Time `db:"revoked_before"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "slices"
+
+// Scopes limit what a credential may do on top of the role of its user.
+const (
+	ScopeFavouritesRead  = "favourites:read"
+	ScopeFavouritesWrite = "favourites:write"
+	ScopeAssetsWrite     = "assets:write"
+	ScopeUsersAdmin      = "users:admin"
+)
+
+var Scopes = []string{ScopeFavouritesRead, ScopeFavouritesWrite, ScopeAssetsWrite, ScopeUsersAdmin}
+
+// ScopesForRole returns the scopes a user with role may be granted. Only
+// admins can write assets or administer users.
+func ScopesForRole(role string) []string {
+	if role == RoleAdmin {
+		return slices.Clone(Scopes)
+	}
+	return []string{ScopeFavouritesRead, ScopeFavouritesWrite}
+}
+
+// ValidScope reports whether scope is one of the known scopes.
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
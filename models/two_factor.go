@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type TOTPEnrollment struct {
+	UserID       string     `db:"user_id"`
+	Secret       string     `db:"secret"`
+	EnabledAt    *time.Time `db:"enabled_at"`
+	LastUsedStep *int64     `db:"last_used_step"`
+}
@@ -1,7 +1,71 @@
 package models
 
+import (
+	"net/mail"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
 type User struct {
-	ID           string `json:"id"`
-	Name         string `json:"name"`
-	PasswordHash string `json:"-"` // Never expose to client
+	ID              string     `json:"id"`
+	TenantID        string     `json:"tenant_id,omitempty"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email,omitempty"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	DisplayName     string     `json:"display_name,omitempty"`
+	AvatarURL       string     `json:"avatar_url,omitempty"`
+	Locale          string     `json:"locale,omitempty"`
+	Role            string     `json:"role"`
+	PasswordHash    string     `json:"-"` // Never expose to client
+	DisabledAt      *time.Time `json:"disabled_at,omitempty"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	LastLoginAt     *time.Time `json:"last_login_at,omitempty"`
+}
+
+// UserProfileUpdate holds the profile fields to change. Nil fields are kept.
+type UserProfileUpdate struct {
+	Name        *string `json:"name"`
+	DisplayName *string `json:"display_name"`
+	AvatarURL   *string `json:"avatar_url"`
+	Locale      *string `json:"locale"`
+}
+
+// EmailUnverified reports whether the user has an email address they have
+// not confirmed yet.
+func (u *User) EmailUnverified() bool {
+	return u.Email != "" && u.EmailVerifiedAt == nil
+}
+
+// ValidRole reports whether role is one of the known user roles.
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleMember
+}
+
+// ValidEmail reports whether email is a bare address such as
+// "alice@example.com", without a display name or angle brackets.
+func ValidEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// ValidAvatarURL reports whether avatar is an absolute http(s) URL.
+func ValidAvatarURL(avatar string) bool {
+	u, err := url.Parse(avatar)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// ValidLocale reports whether locale looks like a BCP 47 language tag such
+// as "en" or "pt-BR".
+func ValidLocale(locale string) bool {
+	return localePattern.MatchString(locale)
 }
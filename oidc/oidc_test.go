@@ -0,0 +1,144 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"platform-go-challenge/oidc"
+	"platform-go-challenge/oidc/oidctest"
+)
+
+const redirectURL = "https://app.example.com/auth/oidc/callback"
+
+func discover(t *testing.T, idp *oidctest.Server) *oidc.Provider {
+	t.Helper()
+
+	provider, err := oidc.Discover(context.Background(), idp.Config(redirectURL))
+	if err != nil {
+		t.Fatalf("Discover error: %v", err)
+	}
+	return provider
+}
+
+// authorize runs the browser leg of the flow and returns the code.
+func authorize(t *testing.T, idp *oidctest.Server, provider *oidc.Provider, state, nonce, verifier string) string {
+	t.Helper()
+
+	callback, err := idp.Authorize(provider.AuthCodeURL(state, nonce, verifier))
+	if err != nil {
+		t.Fatalf("Authorize error: %v", err)
+	}
+	if got := callback.Query().Get("state"); got != state {
+		t.Fatalf("state = %q, want %q", got, state)
+	}
+	return callback.Query().Get("code")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := oidctest.NewServer()
+	defer idp.Close()
+	idp.SetUser(oidctest.User{Subject: "abc", Email: "bob@example.com", EmailVerified: true, Name: "Bob"})
+
+	provider := discover(t, idp)
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		t.Fatalf("GenerateVerifier error: %v", err)
+	}
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", "nonce-1", verifier))
+	if err != nil {
+		t.Fatalf("parse auth url: %v", err)
+	}
+	q := authURL.Query()
+	if q.Get("code_challenge") != oidc.S256Challenge(verifier) || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("auth url does not carry an S256 challenge: %s", authURL)
+	}
+	if strings.Contains(authURL.String(), verifier) {
+		t.Fatal("auth url leaks the code verifier")
+	}
+
+	code := authorize(t, idp, provider, "state-1", "nonce-1", verifier)
+
+	raw, err := provider.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange error: %v", err)
+	}
+
+	token, err := provider.Verify(context.Background(), raw, "nonce-1")
+	if err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+	if token.Subject != "abc" || token.Email != "bob@example.com" || !token.EmailVerified || token.Name != "Bob" {
+		t.Fatalf("unexpected claims: %+v", token)
+	}
+}
+
+func TestExchange_RejectsWrongVerifier(t *testing.T) {
+	idp := oidctest.NewServer()
+	defer idp.Close()
+
+	provider := discover(t, idp)
+	code := authorize(t, idp, provider, "s", "n", "verifier-one-verifier-one-verifier-one-1")
+
+	if _, err := provider.Exchange(context.Background(), code, "verifier-two-verifier-two-verifier-two-2"); err == nil {
+		t.Fatal("expected exchange with the wrong verifier to fail")
+	}
+}
+
+func TestVerify_RejectsNonceMismatch(t *testing.T) {
+	idp := oidctest.NewServer()
+	defer idp.Close()
+
+	provider := discover(t, idp)
+	verifier, _ := oidc.GenerateVerifier()
+	code := authorize(t, idp, provider, "s", "nonce-1", verifier)
+
+	raw, err := provider.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange error: %v", err)
+	}
+
+	if _, err := provider.Verify(context.Background(), raw, "nonce-2"); err == nil {
+		t.Fatal("expected nonce mismatch to be rejected")
+	}
+}
+
+func TestVerify_RejectsOtherAudience(t *testing.T) {
+	idp := oidctest.NewServer()
+	defer idp.Close()
+
+	provider := discover(t, idp)
+	verifier, _ := oidc.GenerateVerifier()
+	code := authorize(t, idp, provider, "s", "n", verifier)
+
+	raw, err := provider.Exchange(context.Background(), code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange error: %v", err)
+	}
+
+	// The same token presented to a different client must not verify
+	config := idp.Config(redirectURL)
+	config.ClientID = "other-client"
+	other, err := oidc.Discover(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Discover error: %v", err)
+	}
+
+	if _, err := other.Verify(context.Background(), raw, "n"); err == nil {
+		t.Fatal("expected audience mismatch to be rejected")
+	}
+}
+
+func TestDiscover_RejectsIssuerMismatch(t *testing.T) {
+	idp := oidctest.NewServer()
+	defer idp.Close()
+
+	config := idp.Config(redirectURL)
+	config.IssuerURL = idp.URL + "/tenant"
+
+	if _, err := oidc.Discover(context.Background(), config); err == nil {
+		t.Fatal("expected discovery to fail")
+	}
+}
@@ -0,0 +1,163 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Params configures how new password hashes are generated. Hashes created
+// with a different algorithm or weaker parameters still verify, but are
+// reported as needing a rehash.
+type Params struct {
+	Algorithm string
+
+	BcryptCost int
+
+	Argon2Memory  uint32 // KiB
+	Argon2Time    uint32
+	Argon2Threads uint8
+	Argon2KeyLen  uint32
+	Argon2SaltLen uint32
+}
+
+// DefaultParams uses argon2id with the RFC 9106 second recommended profile
+// (64 MiB memory, 3 passes) and bcrypt cost 12 when bcrypt is selected.
+func DefaultParams() Params {
+	return Params{
+		Algorithm:     Argon2id,
+		BcryptCost:    12,
+		Argon2Memory:  64 * 1024,
+		Argon2Time:    3,
+		Argon2Threads: 2,
+		Argon2KeyLen:  32,
+		Argon2SaltLen: 16,
+	}
+}
+
+type Hasher struct {
+	params Params
+}
+
+func NewHasher(params Params) (*Hasher, error) {
+	switch params.Algorithm {
+	case Argon2id:
+		if params.Argon2Memory == 0 || params.Argon2Time == 0 || params.Argon2Threads == 0 ||
+			params.Argon2KeyLen == 0 || params.Argon2SaltLen == 0 {
+			return nil, errors.New("argon2id parameters must be positive")
+		}
+	case Bcrypt:
+		if params.BcryptCost < bcrypt.MinCost || params.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", params.Algorithm)
+	}
+
+	return &Hasher{params: params}, nil
+}
+
+// Hash returns an encoded hash of password using the configured algorithm.
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.params.Algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.params.BcryptCost)
+		return string(hash), err
+	}
+
+	salt := make([]byte, h.params.Argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	p := h.params
+	key := argon2.IDKey([]byte(password), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, p.Argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Argon2Memory, p.Argon2Time, p.Argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether password matches encoded and, if it does, whether
+// encoded should be replaced by a fresh Hash because it uses another
+// algorithm or weaker parameters than configured.
+func (h *Hasher) Verify(password, encoded string) (match bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return h.verifyArgon2id(password, encoded)
+
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, false, nil
+			}
+			return false, false, err
+		}
+
+		cost, err := bcrypt.Cost([]byte(encoded))
+		if err != nil {
+			return false, false, err
+		}
+		return true, h.params.Algorithm != Bcrypt || cost < h.params.BcryptCost, nil
+
+	default:
+		return false, false, ErrUnknownHashFormat
+	}
+}
+
+func (h *Hasher) verifyArgon2id(password, encoded string) (bool, bool, error) {
+	// $argon2id$v=19$m=65536,t=3,p=2$salt$key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, ErrUnknownHashFormat
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false, ErrUnknownHashFormat
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(candidate, key) != 1 {
+		return false, false, nil
+	}
+
+	p := h.params
+	needsRehash := p.Algorithm != Argon2id ||
+		memory < p.Argon2Memory ||
+		time < p.Argon2Time ||
+		threads < p.Argon2Threads ||
+		uint32(len(key)) < p.Argon2KeyLen ||
+		uint32(len(salt)) < p.Argon2SaltLen
+
+	return true, needsRehash, nil
+}
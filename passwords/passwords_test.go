@@ -0,0 +1,136 @@
+package passwords
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2 keeps the tests quick while still exercising argon2id.
+func fastArgon2() Params {
+	p := DefaultParams()
+	p.Argon2Memory = 1024
+	p.Argon2Time = 1
+	p.Argon2Threads = 1
+	return p
+}
+
+func mustHasher(t *testing.T, p Params) *Hasher {
+	t.Helper()
+	h, err := NewHasher(p)
+	if err != nil {
+		t.Fatalf("NewHasher error: %v", err)
+	}
+	return h
+}
+
+func TestHasher_Argon2idRoundTrip(t *testing.T) {
+	h := mustHasher(t, fastArgon2())
+
+	encoded, err := h.Hash("alice123")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected encoding: %s", encoded)
+	}
+
+	match, rehash, err := h.Verify("alice123", encoded)
+	if err != nil || !match || rehash {
+		t.Fatalf("Verify = %v, %v, %v; want true, false, nil", match, rehash, err)
+	}
+
+	match, _, err = h.Verify("wrong", encoded)
+	if err != nil || match {
+		t.Fatalf("Verify wrong password = %v, %v; want false, nil", match, err)
+	}
+}
+
+func TestHasher_SeededBcryptHashesVerify(t *testing.T) {
+	h := mustHasher(t, fastArgon2())
+
+	// Seeded in db/init/001_init.sql
+	seeded := map[string]string{
+		"alice123": "$2a$10$q8PPH5ykvZ24Sq9Gu0QC6OfYVWYw5cQnczvDcUC3HWjDDixSf.I3.",
+		"bob123":   "$2a$10$HXoxscLQW5pjFX3CxTyka./faujDQzJzlLgFPcE3zZ0cnd.RNRMHe",
+	}
+
+	for password, encoded := range seeded {
+		match, rehash, err := h.Verify(password, encoded)
+		if err != nil || !match {
+			t.Fatalf("Verify(%s) = %v, %v; want match", password, match, err)
+		}
+		if !rehash {
+			t.Fatalf("expected bcrypt hash to need rehash to argon2id")
+		}
+	}
+}
+
+func TestHasher_BcryptCostUpgrade(t *testing.T) {
+	p := DefaultParams()
+	p.Algorithm = Bcrypt
+	p.BcryptCost = bcrypt.MinCost + 1
+	h := mustHasher(t, p)
+
+	weak, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if match, rehash, _ := h.Verify("secret", string(weak)); !match || !rehash {
+		t.Fatalf("weaker bcrypt cost: match=%v rehash=%v, want true true", match, rehash)
+	}
+
+	current, err := h.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+	if match, rehash, _ := h.Verify("secret", current); !match || rehash {
+		t.Fatalf("current bcrypt cost: match=%v rehash=%v, want true false", match, rehash)
+	}
+}
+
+func TestHasher_Argon2idParameterUpgrade(t *testing.T) {
+	old := mustHasher(t, fastArgon2())
+	encoded, err := old.Hash("secret")
+	if err != nil {
+		t.Fatalf("Hash error: %v", err)
+	}
+
+	stronger := fastArgon2()
+	stronger.Argon2Time = 2
+	h := mustHasher(t, stronger)
+
+	if match, rehash, _ := h.Verify("secret", encoded); !match || !rehash {
+		t.Fatalf("match=%v rehash=%v, want true true", match, rehash)
+	}
+
+	// Switching to bcrypt also flags argon2id hashes
+	p := DefaultParams()
+	p.Algorithm = Bcrypt
+	if match, rehash, _ := mustHasher(t, p).Verify("secret", encoded); !match || !rehash {
+		t.Fatalf("match=%v rehash=%v, want true true", match, rehash)
+	}
+}
+
+func TestHasher_UnknownFormat(t *testing.T) {
+	h := mustHasher(t, fastArgon2())
+
+	for _, encoded := range []string{"", "plaintext", "$argon2id$v=19$garbage"} {
+		if match, _, err := h.Verify("secret", encoded); match || err == nil {
+			t.Fatalf("Verify(%q) = %v, %v; want false and an error", encoded, match, err)
+		}
+	}
+}
+
+func TestNewHasher_InvalidParams(t *testing.T) {
+	p := DefaultParams()
+	p.Algorithm = "md5"
+	if _, err := NewHasher(p); err == nil {
+		t.Fatal("expected error for unsupported algorithm")
+	}
+
+	p = DefaultParams()
+	p.Algorithm = Bcrypt
+	p.BcryptCost = 100
+	if _, err := NewHasher(p); err == nil {
+		t.Fatal("expected error for out of range bcrypt cost")
+	}
+}
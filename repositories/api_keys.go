@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"platform-go-challenge/models"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var k models.APIKey
+	var scopes string
+	err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &scopes,
+		&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	k.Scopes = strings.Fields(scopes)
+	return &k, nil
+}
+
+// CreateAPIKey returns ErrUserNotFound when the user is not in the tenant.
+func CreateAPIKey(
+	ctx context.Context,
+	db *sql.DB,
+	key models.APIKey,
+) (*models.APIKey, error) {
+	query := `
+	INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at)
+	SELECT $1, $2, $3, $4, $5, $6, $7::timestamp
+	WHERE $2 IN (SELECT id FROM users WHERE tenant_id = $8)
+	RETURNING ` + apiKeyColumns + `;
+	`
+
+	k, err := scanAPIKey(db.QueryRowContext(ctx, query,
+		key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, strings.Join(key.Scopes, " "), key.ExpiresAt,
+		TenantFromContext(ctx)))
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+
+	return k, err
+}
+
+// ListAPIKeys returns the keys of userID, including revoked ones, newest first.
+func ListAPIKeys(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) ([]models.APIKey, error) {
+	query := `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys
+	WHERE user_id = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)
+	ORDER BY created_at DESC, id;
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+
+	return keys, rows.Err()
+}
+
+// GetAPIKey returns key keyID of userID, or nil when it does not exist.
+func GetAPIKey(
+	ctx context.Context,
+	db *sql.DB,
+	userID, keyID string,
+) (*models.APIKey, error) {
+	query := `
+	SELECT ` + apiKeyColumns + `
+	FROM api_keys
+	WHERE user_id = $1 AND id = $2 AND user_id IN (SELECT id FROM users WHERE tenant_id = $3);
+	`
+
+	k, err := scanAPIKey(db.QueryRowContext(ctx, query, userID, keyID, TenantFromContext(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// GetActiveAPIKeyByHash returns the unrevoked, unexpired key with keyHash
+// and the tenant of its owner, or nil when there is none. Keys are looked up
+// in every tenant, like tokens carry theirs, so callers must check the
+// tenant against the request.
+func GetActiveAPIKeyByHash(
+	ctx context.Context,
+	db *sql.DB,
+	keyHash string,
+) (*models.APIKey, string, error) {
+	query := `
+	SELECT ` + apiKeyColumns + `, (SELECT tenant_id FROM users WHERE users.id = api_keys.user_id)
+	FROM api_keys
+	WHERE key_hash = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > now());
+	`
+
+	var k models.APIKey
+	var scopes, tenantID string
+	err := db.QueryRowContext(ctx, query, keyHash).Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &scopes,
+		&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt, &tenantID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	k.Scopes = strings.Fields(scopes)
+	return &k, tenantID, nil
+}
+
+// UpdateAPIKey renames an unrevoked key of userID and replaces its scopes.
+func UpdateAPIKey(
+	ctx context.Context,
+	db *sql.DB,
+	userID, keyID, name string,
+	scopes []string,
+) (*models.APIKey, error) {
+	query := `
+	UPDATE api_keys
+	SET name = $3, scopes = $4
+	WHERE user_id = $1 AND id = $2 AND revoked_at IS NULL
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $5)
+	RETURNING ` + apiKeyColumns + `;
+	`
+
+	k, err := scanAPIKey(db.QueryRowContext(ctx, query, userID, keyID, name, strings.Join(scopes, " "),
+		TenantFromContext(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// RevokeAPIKey revokes an unrevoked key of userID.
+func RevokeAPIKey(
+	ctx context.Context,
+	db *sql.DB,
+	userID, keyID string,
+) error {
+	query := `
+	UPDATE api_keys
+	SET revoked_at = now()
+	WHERE user_id = $1 AND id = $2 AND revoked_at IS NULL
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $3);
+	`
+
+	result, err := db.ExecContext(ctx, query, userID, keyID, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKey records that keyID was just used. To avoid a write on every
+// request, last_used_at is only updated once it is older than resolution.
+func TouchAPIKey(
+	ctx context.Context,
+	db *sql.DB,
+	keyID string,
+	resolution time.Duration,
+) error {
+	query := `
+	UPDATE api_keys
+	SET last_used_at = now()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $2)
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $3);
+	`
+
+	_, err := db.ExecContext(ctx, query, keyID, time.Now().Add(-resolution), TenantFromContext(ctx))
+	return err
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+var apiKeyTestColumns = []string{"id", "user_id", "name", "prefix", "key_hash", "scopes", "expires_at", "last_used_at", "revoked_at", "created_at"}
+
+func TestCreateAPIKey_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("INSERT INTO api_keys").
+		WithArgs("k1", "u1", "etl", "pgc_abcd1234", "hash", "assets:write favourites:read", nil, "default").
+		WillReturnRows(sqlmock.NewRows(apiKeyTestColumns).
+			AddRow("k1", "u1", "etl", "pgc_abcd1234", "hash", "assets:write favourites:read", nil, nil, nil, now))
+
+	key, err := CreateAPIKey(context.Background(), db, models.APIKey{
+		ID:      "k1",
+		UserID:  "u1",
+		Name:    "etl",
+		Prefix:  "pgc_abcd1234",
+		KeyHash: "hash",
+		Scopes:  []string{"assets:write", "favourites:read"},
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey error: %v", err)
+	}
+	if len(key.Scopes) != 2 || key.Scopes[0] != "assets:write" {
+		t.Fatalf("unexpected scopes: %v", key.Scopes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestListAPIKeys_Empty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, user_id, name, prefix").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(apiKeyTestColumns))
+
+	keys, err := ListAPIKeys(context.Background(), db, "u1")
+	if err != nil {
+		t.Fatalf("ListAPIKeys error: %v", err)
+	}
+	if keys == nil || len(keys) != 0 {
+		t.Fatalf("expected empty non-nil slice, got %#v", keys)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetActiveAPIKeyByHash_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT id, user_id, name, prefix").
+		WithArgs("hash").
+		WillReturnError(sql.ErrNoRows)
+
+	key, _, err := GetActiveAPIKeyByHash(context.Background(), db, "hash")
+	if err != nil || key != nil {
+		t.Fatalf("GetActiveAPIKeyByHash = %+v, %v; want nil, nil", key, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevokeAPIKey_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE api_keys").
+		WithArgs("u1", "k1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = RevokeAPIKey(context.Background(), db, "u1", "k1")
+	if !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Fatalf("RevokeAPIKey error = %v, want ErrAPIKeyNotFound", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestTouchAPIKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE api_keys").
+		WithArgs("k1", sqlmock.AnyArg(), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := TouchAPIKey(context.Background(), db, "k1", time.Minute); err != nil {
+		t.Fatalf("TouchAPIKey error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
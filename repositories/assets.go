@@ -4,11 +4,40 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"platform-go-challenge/models"
 )
 
+var ErrAssetNotFound = errors.New("asset not found")
+
+const assetColumns = `a.id, a.type, a.title, a.data, a.created_at, a.org_id, a.created_by, a.visibility`
+
+func scanAsset(row rowScanner) (*models.Asset, error) {
+	var a models.Asset
+	err := row.Scan(&a.ID, &a.Type, &a.Title, &a.Data, &a.CreatedAt, &a.OrgID, &a.CreatedBy, &a.Visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// assetVisible is the condition under which the asset a is visible to the
+// user bound to $n, who is an admin when $n+1 is true: public assets, their
+// own, and those shared with an organization they belong to. Admins see
+// every asset.
+func assetVisible(n int) string {
+	return fmt.Sprintf(`(a.visibility = 'public'
+		OR a.created_by = $%[1]d
+		OR (a.visibility = 'org' AND a.org_id IN (
+			SELECT org_id FROM organization_members WHERE user_id = $%[1]d
+		))
+		OR $%[2]d)`, n, n+1)
+}
+
+// CreateAsset stores asset as created by its CreatedBy user.
 func CreateAsset(
 	ctx context.Context,
 	db *sql.DB,
@@ -16,46 +45,62 @@ func CreateAsset(
 	description *string,
 ) (string, error) {
 	query := `
-	INSERT INTO assets (type, title, description, data)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO assets (type, title, description, data, org_id, tenant_id, created_by, visibility)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 	RETURNING id;
 	`
 
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
 	var assetID string
-	err := db.QueryRowContext(
+	err = tx.QueryRowContext(
 		ctx,
 		query,
 		asset.Type,
 		asset.Title,
 		description,
 		asset.Data,
+		asset.OrgID,
+		TenantFromContext(ctx),
+		asset.CreatedBy,
+		asset.Visibility,
 	).Scan(&assetID)
 	if err != nil {
 		return "", err
 	}
 
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
 	return assetID, nil
 }
 
+// GetAssetByID returns nil when the asset does not exist or is not visible
+// to the current user.
 func GetAssetByID(
 	ctx context.Context,
 	db *sql.DB,
 	assetID string,
 ) (*models.Asset, error) {
 	query := `
-	SELECT id, type, title, data, created_at
-	FROM assets
-	WHERE id = $1;
+	SELECT ` + assetColumns + `
+	FROM assets a
+	WHERE a.id = $1 AND a.tenant_id = $2 AND ` + assetVisible(3) + `;
 	`
 
-	var asset models.Asset
-	err := db.QueryRowContext(ctx, query, assetID).Scan(
-		&asset.ID,
-		&asset.Type,
-		&asset.Title,
-		&asset.Data,
-		&asset.CreatedAt,
-	)
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user := currentUserFromContext(ctx)
+	asset, err := scanAsset(tx.QueryRowContext(ctx, query, assetID, TenantFromContext(ctx), user.id, user.admin()))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -63,20 +108,35 @@ func GetAssetByID(
 		return nil, err
 	}
 
-	return &asset, nil
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return asset, nil
 }
 
+// ListAssets returns every asset visible to the current user, or only those
+// of orgID when it is set.
 func ListAssets(
 	ctx context.Context,
 	db *sql.DB,
+	orgID string,
 ) ([]models.Asset, error) {
 	query := `
-	SELECT id, type, title, data, created_at
-	FROM assets
-	ORDER BY created_at DESC;
+	SELECT ` + assetColumns + `
+	FROM assets a
+	WHERE a.tenant_id = $2 AND ($1 = '' OR a.org_id = $1) AND ` + assetVisible(3) + `
+	ORDER BY a.created_at DESC;
 	`
 
-	rows, err := db.QueryContext(ctx, query)
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user := currentUserFromContext(ctx)
+	rows, err := tx.QueryContext(ctx, query, orgID, TenantFromContext(ctx), user.id, user.admin())
 	if err != nil {
 		return nil, err
 	}
@@ -84,16 +144,21 @@ func ListAssets(
 
 	var assets []models.Asset
 	for rows.Next() {
-		var a models.Asset
-		if err := rows.Scan(&a.ID, &a.Type, &a.Title, &a.Data, &a.CreatedAt); err != nil {
+		a, err := scanAsset(rows)
+		if err != nil {
 			return nil, err
 		}
-		assets = append(assets, a)
+		assets = append(assets, *a)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	return assets, nil
 }
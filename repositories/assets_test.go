@@ -76,17 +76,21 @@ func TestCreateAsset_DB(t *testing.T) {
 	defer db.Close()
 
 	asset := models.Asset{
-		Type:  models.AssetChart,
-		Title: ptrString("Sales"),
-		Data:  json.RawMessage(`{"points":[1,2]}`),
+		Type:       models.AssetChart,
+		Title:      ptrString("Sales"),
+		Data:       json.RawMessage(`{"points":[1,2]}`),
+		CreatedBy:  ptrString("u1"),
+		Visibility: models.VisibilityPrivate,
 	}
 	desc := ptrString("Monthly data")
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectQuery("INSERT INTO assets").
-		WithArgs(models.AssetChart, ptrString("Sales"), desc, json.RawMessage(`{"points":[1,2]}`)).
+		WithArgs(models.AssetChart, ptrString("Sales"), desc, json.RawMessage(`{"points":[1,2]}`), nil, "default", ptrString("u1"), models.VisibilityPrivate).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1"))
+	mock.ExpectCommit()
 
-	id, err := CreateAsset(context.Background(), db, asset, desc)
+	id, err := CreateAsset(userContext("u1"), db, asset, desc)
 	if err != nil {
 		t.Fatalf("CreateAsset error: %v", err)
 	}
@@ -111,10 +115,12 @@ func TestCreateAsset_DBError(t *testing.T) {
 		Title: ptrString("Sales"),
 	}
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectQuery("INSERT INTO assets").
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	id, err := CreateAsset(context.Background(), db, asset, nil)
+	id, err := CreateAsset(userContext("u1"), db, asset, nil)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -135,12 +141,14 @@ func TestGetAssetByID_DB_Found(t *testing.T) {
 	defer db.Close()
 
 	createdAt := time.Now()
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").
-		WithArgs("a1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "title", "data", "created_at"}).
-			AddRow("a1", models.AssetChart, ptrString("Sales"), json.RawMessage(`{}`), createdAt))
-
-	asset, err := GetAssetByID(context.Background(), db, "a1")
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("a1", "default", "u1", false).
+		WillReturnRows(sqlmock.NewRows(assetTestColumns).
+			AddRow("a1", models.AssetChart, ptrString("Sales"), json.RawMessage(`{}`), createdAt, nil, ptrString("u1"), models.VisibilityPrivate))
+	mock.ExpectCommit()
+
+	asset, err := GetAssetByID(userContext("u1"), db, "a1")
 	if err != nil {
 		t.Fatalf("GetAssetByID error: %v", err)
 	}
@@ -163,11 +171,13 @@ func TestGetAssetByID_DB_NotFound(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").
-		WithArgs("missing").
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("missing", "default", "u1", false).
 		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
 
-	asset, err := GetAssetByID(context.Background(), db, "missing")
+	asset, err := GetAssetByID(userContext("u1"), db, "missing")
 	if err != nil {
 		t.Fatalf("GetAssetByID error: %v", err)
 	}
@@ -187,11 +197,13 @@ func TestGetAssetByID_DB_Error(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").
-		WithArgs("a1").
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("a1", "default", "u1", false).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	asset, err := GetAssetByID(context.Background(), db, "a1")
+	asset, err := GetAssetByID(userContext("u1"), db, "a1")
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -204,6 +216,36 @@ func TestGetAssetByID_DB_Error(t *testing.T) {
 	}
 }
 
+func TestListAssets_DB_AdminSeesEveryAsset(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(assetTestColumns).
+		AddRow("a1", models.AssetChart, ptrString("Chart"), json.RawMessage(`{}`), time.Now(), nil, ptrString("u2"), models.VisibilityPrivate)
+
+	expectUserTx(mock, "admin1", models.RoleAdmin)
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("", "default", "admin1", true).
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	ctx := WithCurrentUser(context.Background(), "admin1", models.RoleAdmin)
+	assets, err := ListAssets(ctx, db, "")
+	if err != nil {
+		t.Fatalf("ListAssets error: %v", err)
+	}
+	if len(assets) != 1 || assets[0].Visibility != models.VisibilityPrivate {
+		t.Fatalf("unexpected assets: %+v", assets)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestListAssets_DB_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -212,14 +254,17 @@ func TestListAssets_DB_Success(t *testing.T) {
 	defer db.Close()
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "type", "title", "data", "created_at"}).
-		AddRow("a1", models.AssetChart, ptrString("Chart"), json.RawMessage(`{}`), now).
-		AddRow("a2", models.AssetInsight, ptrString("Insight"), json.RawMessage(`{}`), now)
+	rows := sqlmock.NewRows(assetTestColumns).
+		AddRow("a1", models.AssetChart, ptrString("Chart"), json.RawMessage(`{}`), now, nil, ptrString("u1"), models.VisibilityPrivate).
+		AddRow("a2", models.AssetInsight, ptrString("Insight"), json.RawMessage(`{}`), now, nil, nil, models.VisibilityPublic)
 
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("", "default", "u1", false).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	assets, err := ListAssets(context.Background(), db)
+	assets, err := ListAssets(userContext("u1"), db, "")
 	if err != nil {
 		t.Fatalf("ListAssets error: %v", err)
 	}
@@ -242,12 +287,15 @@ func TestListAssets_DB_Empty(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "type", "title", "data", "created_at"})
+	rows := sqlmock.NewRows(assetTestColumns)
 
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectQuery("FROM assets a").
+		WithArgs("", "default", "u1", false).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	assets, err := ListAssets(context.Background(), db)
+	assets, err := ListAssets(userContext("u1"), db, "")
 	if err != nil {
 		t.Fatalf("ListAssets error: %v", err)
 	}
@@ -267,10 +315,12 @@ func TestListAssets_DB_Error(t *testing.T) {
 	}
 	defer db.Close()
 
-	mock.ExpectQuery("SELECT id, type, title, data, created_at").
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectQuery("FROM assets a").
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	assets, err := ListAssets(context.Background(), db)
+	assets, err := ListAssets(userContext("u1"), db, "")
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -351,6 +401,8 @@ func TestGetAssetByID(t *testing.T) {
 	}
 }
 
+var assetTestColumns = []string{"id", "type", "title", "data", "created_at", "org_id", "created_by", "visibility"}
+
 // Helper functions
 func ptrString(s string) *string {
 	return &s
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"platform-go-challenge/models"
+)
+
+func RecordAuditEvent(
+	ctx context.Context,
+	db *sql.DB,
+	event models.AuditEvent,
+) error {
+	query := `
+	INSERT INTO audit_log (actor_id, user_id, action, detail)
+	SELECT $1, $2, $3, NULLIF($4, '')
+	WHERE $1 IN (SELECT id FROM users WHERE tenant_id = $5);
+	`
+
+	res, err := db.ExecContext(ctx, query, event.ActorID, event.UserID, event.Action, event.Detail,
+		TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ListAuditEvents returns the audit events where userID is the actor or the
+// subject, newest first.
+func ListAuditEvents(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) ([]models.AuditEvent, error) {
+	query := `
+	SELECT id, actor_id, user_id, action, COALESCE(detail, ''), created_at
+	FROM audit_log
+	WHERE (user_id = $1 OR actor_id = $1)
+		AND $1 IN (SELECT id FROM users WHERE tenant_id = $2)
+	ORDER BY created_at DESC, id DESC;
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var e models.AuditEvent
+		if err := rows.Scan(&e.ID, &e.ActorID, &e.UserID, &e.Action, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+func TestRecordAuditEvent_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("u1", "u2", models.AuditImpersonationStart, "ticket 42", "default").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = RecordAuditEvent(context.Background(), db, models.AuditEvent{
+		ActorID: "u1",
+		UserID:  "u2",
+		Action:  models.AuditImpersonationStart,
+		Detail:  "ticket 42",
+	})
+	if err != nil {
+		t.Fatalf("RecordAuditEvent error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
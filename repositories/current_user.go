@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"platform-go-challenge/models"
+)
+
+type currentUserContextKey struct{}
+
+type currentUser struct {
+	id   string
+	role string
+}
+
+// WithCurrentUser returns a copy of ctx in which the row-level security
+// policies of the database see userID, with role, as the current user.
+func WithCurrentUser(ctx context.Context, userID, role string) context.Context {
+	return context.WithValue(ctx, currentUserContextKey{}, currentUser{id: userID, role: role})
+}
+
+func currentUserFromContext(ctx context.Context) currentUser {
+	user, _ := ctx.Value(currentUserContextKey{}).(currentUser)
+	return user
+}
+
+func (u currentUser) admin() bool {
+	return u.role == models.RoleAdmin
+}
+
+// beginUserTx begins a transaction in which app.current_user and
+// app.current_role are set to the current user of ctx. Without a current
+// user the policies hide every row they protect.
+func beginUserTx(
+	ctx context.Context,
+	db *sql.DB,
+) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	user := currentUserFromContext(ctx)
+
+	query := `
+	SELECT set_config('app.current_user', $1, true), set_config('app.current_role', $2, true);
+	`
+
+	if _, err := tx.ExecContext(ctx, query, user.id, user.role); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
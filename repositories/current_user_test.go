@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+// userContext returns a context whose current user is the member userID.
+func userContext(userID string) context.Context {
+	return WithCurrentUser(context.Background(), userID, models.RoleMember)
+}
+
+// expectUserTx expects beginUserTx to start a transaction for userID.
+func expectUserTx(mock sqlmock.Sqlmock, userID, role string) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`set_config\('app.current_user', \$1, true\), set_config\('app.current_role', \$2, true\)`).
+		WithArgs(userID, role).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestBeginUserTx_WithoutCurrentUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	// The policies see no user and hide every row
+	expectUserTx(mock, "", "")
+	mock.ExpectRollback()
+
+	tx, err := beginUserTx(context.Background(), db)
+	if err != nil {
+		t.Fatalf("beginUserTx error: %v", err)
+	}
+	tx.Rollback()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
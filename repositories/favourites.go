@@ -9,10 +9,12 @@ import (
 	"platform-go-challenge/models"
 )
 
+// GetUserFavourites returns the favourites of userID, limited to the assets
+// of orgID when it is set.
 func GetUserFavourites(
 	ctx context.Context,
 	db *sql.DB,
-	userID string,
+	userID, orgID string,
 ) ([]models.FavouriteAsset, error) {
 
 	query := `
@@ -24,11 +26,18 @@ func GetUserFavourites(
 		f.description
 	FROM favourites f
 	JOIN assets a ON a.id = f.asset_id
-	WHERE f.user_id = $1
+	WHERE f.user_id = $1 AND f.tenant_id = $3 AND ($2 = '' OR a.org_id = $2) AND ` + assetVisible(4) + `
 	ORDER BY f.created_at DESC;
 	`
 
-	rows, err := db.QueryContext(ctx, query, userID)
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user := currentUserFromContext(ctx)
+	rows, err := tx.QueryContext(ctx, query, userID, orgID, TenantFromContext(ctx), user.id, user.admin())
 	if err != nil {
 		return nil, err
 	}
@@ -68,10 +77,89 @@ func GetUserFavourites(
 			Description: description,
 		})
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListFavouritesForExport returns every favourite of userID with its
+// description, including favourites of assets the current user can no longer
+// see. Only the ID of those assets is returned, not their content.
+func ListFavouritesForExport(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) ([]models.FavouriteAsset, error) {
+
+	query := `
+	SELECT
+		f.asset_id,
+		COALESCE(a.type, ''),
+		a.title,
+		a.data,
+		f.description
+	FROM favourites f
+	LEFT JOIN assets a ON a.id = f.asset_id AND ` + assetVisible(3) + `
+	WHERE f.user_id = $1 AND f.tenant_id = $2
+	ORDER BY f.created_at DESC;
+	`
+
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user := currentUserFromContext(ctx)
+	rows, err := tx.QueryContext(ctx, query, userID, TenantFromContext(ctx), user.id, user.admin())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []models.FavouriteAsset{}
+
+	for rows.Next() {
+		var (
+			f       models.FavouriteAsset
+			rawData []byte
+		)
+
+		if err := rows.Scan(&f.AssetID, &f.Type, &f.Title, &rawData, &f.Description); err != nil {
+			return nil, err
+		}
+
+		if f.Type != "" {
+			f.Data, err = unmarshalAssetData(f.Type, rawData)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result = append(result, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
 
+// AddFavourite adds assetID to the favourites of userID, or updates the
+// description when it already is one. It returns ErrAssetNotFound when the
+// asset does not exist in the tenant or is not visible to the current user.
 func AddFavourite(
 	ctx context.Context,
 	db *sql.DB,
@@ -80,21 +168,41 @@ func AddFavourite(
 ) error {
 
 	query := `
-	INSERT INTO favourites (user_id, asset_id, description)
-	VALUES ($1, $2, $3)
+	INSERT INTO favourites (user_id, asset_id, description, tenant_id)
+	SELECT $1, a.id, $3, a.tenant_id
+	FROM assets a
+	WHERE a.id = $2 AND a.tenant_id = $4 AND ` + assetVisible(5) + `
 	ON CONFLICT (user_id, asset_id)
 	DO UPDATE SET description = EXCLUDED.description;
 	`
 
-	_, err := db.ExecContext(
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	user := currentUserFromContext(ctx)
+	res, err := tx.ExecContext(
 		ctx,
 		query,
 		userID,
 		assetID,
 		description,
+		TenantFromContext(ctx),
+		user.id,
+		user.admin(),
 	)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrAssetNotFound
+	}
 
-	return err
+	return tx.Commit()
 }
 
 func RemoveFavourite(
@@ -105,10 +213,16 @@ func RemoveFavourite(
 
 	query := `
 	DELETE FROM favourites
-	WHERE user_id = $1 AND asset_id = $2;
+	WHERE user_id = $1 AND asset_id = $2 AND tenant_id = $3;
 	`
 
-	res, err := db.ExecContext(ctx, query, userID, assetID)
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, userID, assetID, TenantFromContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -118,7 +232,7 @@ func RemoveFavourite(
 		return errors.New("favourite not found")
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 func UpdateFavouriteDescription(
@@ -131,15 +245,22 @@ func UpdateFavouriteDescription(
 	query := `
 	UPDATE favourites
 	SET description = $3
-	WHERE user_id = $1 AND asset_id = $2;
+	WHERE user_id = $1 AND asset_id = $2 AND tenant_id = $4;
 	`
 
-	res, err := db.ExecContext(
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(
 		ctx,
 		query,
 		userID,
 		assetID,
 		description,
+		TenantFromContext(ctx),
 	)
 	if err != nil {
 		return err
@@ -150,5 +271,5 @@ func UpdateFavouriteDescription(
 		return errors.New("favourite not found")
 	}
 
-	return nil
+	return tx.Commit()
 }
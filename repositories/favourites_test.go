@@ -1,7 +1,6 @@
 package repositories
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
 	"testing"
@@ -21,11 +20,13 @@ func TestGetUserFavourites_Success(t *testing.T) {
 	rows := sqlmock.NewRows([]string{"id", "type", "title", "data", "description"}).
 		AddRow("a1", models.AssetChart, ptrString("Sales"), json.RawMessage(`{}`), nil)
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectQuery("SELECT").
-		WithArgs("u1").
+		WithArgs("u1", "", "default", "u1", false).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	favs, err := GetUserFavourites(context.Background(), db, "u1")
+	favs, err := GetUserFavourites(userContext("u1"), db, "u1", "")
 	if err != nil {
 		t.Fatalf("GetUserFavourites error: %v", err)
 	}
@@ -50,11 +51,13 @@ func TestGetUserFavourites_Empty(t *testing.T) {
 
 	rows := sqlmock.NewRows([]string{"id", "type", "title", "data", "description"})
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectQuery("SELECT").
-		WithArgs("u1").
+		WithArgs("u1", "", "default", "u1", false).
 		WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	favs, err := GetUserFavourites(context.Background(), db, "u1")
+	favs, err := GetUserFavourites(userContext("u1"), db, "u1", "")
 	if err != nil {
 		t.Fatalf("GetUserFavourites error: %v", err)
 	}
@@ -74,11 +77,13 @@ func TestGetUserFavourites_DBError(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectQuery("SELECT").
-		WithArgs("u1").
+		WithArgs("u1", "", "default", "u1", false).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	favs, err := GetUserFavourites(context.Background(), db, "u1")
+	favs, err := GetUserFavourites(userContext("u1"), db, "u1", "")
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -91,6 +96,39 @@ func TestGetUserFavourites_DBError(t *testing.T) {
 	}
 }
 
+func TestListFavouritesForExport_KeepsHiddenAssets(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"asset_id", "type", "title", "data", "description"}).
+		AddRow("a1", models.AssetChart, ptrString("Sales"), json.RawMessage(`{}`), nil).
+		AddRow("a2", "", nil, nil, ptrString("private now"))
+
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectQuery("LEFT JOIN assets a").
+		WithArgs("u1", "default", "u1", false).
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	favs, err := ListFavouritesForExport(userContext("u1"), db, "u1")
+	if err != nil {
+		t.Fatalf("ListFavouritesForExport error: %v", err)
+	}
+	if len(favs) != 2 {
+		t.Fatalf("expected 2 favourites, got %d", len(favs))
+	}
+	if favs[1].AssetID != "a2" || favs[1].Data != nil || *favs[1].Description != "private now" {
+		t.Fatalf("unexpected favourite: %+v", favs[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestAddFavourite_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -98,11 +136,13 @@ func TestAddFavourite_Success(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("INSERT INTO favourites").
-		WithArgs("u1", "a1", nil).
+		WithArgs("u1", "a1", nil, "default", "u1", false).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	err = AddFavourite(context.Background(), db, "u1", "a1", nil)
+	err = AddFavourite(userContext("u1"), db, "u1", "a1", nil)
 	if err != nil {
 		t.Fatalf("AddFavourite error: %v", err)
 	}
@@ -120,11 +160,13 @@ func TestAddFavourite_WithDescription(t *testing.T) {
 	defer db.Close()
 
 	desc := ptrString("My favourite")
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("INSERT INTO favourites").
-		WithArgs("u1", "a1", desc).
+		WithArgs("u1", "a1", desc, "default", "u1", false).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	err = AddFavourite(context.Background(), db, "u1", "a1", desc)
+	err = AddFavourite(userContext("u1"), db, "u1", "a1", desc)
 	if err != nil {
 		t.Fatalf("AddFavourite error: %v", err)
 	}
@@ -141,11 +183,13 @@ func TestAddFavourite_DBError(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("INSERT INTO favourites").
-		WithArgs("u1", "a1", nil).
+		WithArgs("u1", "a1", nil, "default", "u1", false).
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	err = AddFavourite(context.Background(), db, "u1", "a1", nil)
+	err = AddFavourite(userContext("u1"), db, "u1", "a1", nil)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -162,11 +206,13 @@ func TestRemoveFavourite_Success(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("DELETE FROM favourites").
-		WithArgs("u1", "a1").
+		WithArgs("u1", "a1", "default").
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	err = RemoveFavourite(context.Background(), db, "u1", "a1")
+	err = RemoveFavourite(userContext("u1"), db, "u1", "a1")
 	if err != nil {
 		t.Fatalf("RemoveFavourite error: %v", err)
 	}
@@ -183,11 +229,13 @@ func TestRemoveFavourite_NotFound(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("DELETE FROM favourites").
-		WithArgs("u1", "missing").
+		WithArgs("u1", "missing", "default").
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
 
-	err = RemoveFavourite(context.Background(), db, "u1", "missing")
+	err = RemoveFavourite(userContext("u1"), db, "u1", "missing")
 	if err == nil {
 		t.Fatal("expected error for missing favourite")
 	}
@@ -204,11 +252,13 @@ func TestRemoveFavourite_DBError(t *testing.T) {
 	}
 	defer db.Close()
 
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("DELETE FROM favourites").
-		WithArgs("u1", "a1").
+		WithArgs("u1", "a1", "default").
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	err = RemoveFavourite(context.Background(), db, "u1", "a1")
+	err = RemoveFavourite(userContext("u1"), db, "u1", "a1")
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -226,11 +276,13 @@ func TestUpdateFavouriteDescription_Success(t *testing.T) {
 	defer db.Close()
 
 	desc := ptrString("Updated description")
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("UPDATE favourites").
-		WithArgs("u1", "a1", desc).
+		WithArgs("u1", "a1", desc, "default").
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	err = UpdateFavouriteDescription(context.Background(), db, "u1", "a1", desc)
+	err = UpdateFavouriteDescription(userContext("u1"), db, "u1", "a1", desc)
 	if err != nil {
 		t.Fatalf("UpdateFavouriteDescription error: %v", err)
 	}
@@ -248,11 +300,13 @@ func TestUpdateFavouriteDescription_NotFound(t *testing.T) {
 	defer db.Close()
 
 	desc := ptrString("Updated")
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("UPDATE favourites").
-		WithArgs("u1", "missing", desc).
+		WithArgs("u1", "missing", desc, "default").
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
 
-	err = UpdateFavouriteDescription(context.Background(), db, "u1", "missing", desc)
+	err = UpdateFavouriteDescription(userContext("u1"), db, "u1", "missing", desc)
 	if err == nil {
 		t.Fatal("expected error for missing favourite")
 	}
@@ -270,11 +324,13 @@ func TestUpdateFavouriteDescription_DBError(t *testing.T) {
 	defer db.Close()
 
 	desc := ptrString("Updated")
+	expectUserTx(mock, "u1", models.RoleMember)
 	mock.ExpectExec("UPDATE favourites").
-		WithArgs("u1", "a1", desc).
+		WithArgs("u1", "a1", desc, "default").
 		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
-	err = UpdateFavouriteDescription(context.Background(), db, "u1", "a1", desc)
+	err = UpdateFavouriteDescription(userContext("u1"), db, "u1", "a1", desc)
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
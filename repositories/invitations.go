@@ -0,0 +1,166 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"platform-go-challenge/models"
+)
+
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+const invitationColumns = `id, code_hash, COALESCE(email, ''), role, COALESCE(created_by, ''),
+	expires_at, used_at, COALESCE(used_by, ''), revoked_at, created_at`
+
+func scanInvitation(row rowScanner) (*models.Invitation, error) {
+	var inv models.Invitation
+	err := row.Scan(&inv.ID, &inv.CodeHash, &inv.Email, &inv.Role, &inv.CreatedBy,
+		&inv.ExpiresAt, &inv.UsedAt, &inv.UsedBy, &inv.RevokedAt, &inv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &inv, nil
+}
+
+func CreateInvitation(
+	ctx context.Context,
+	db *sql.DB,
+	inv models.Invitation,
+) (*models.Invitation, error) {
+	query := `
+	INSERT INTO invitations (id, code_hash, email, role, created_by, expires_at, tenant_id)
+	VALUES ($1, $2, NULLIF($3, ''), $4, NULLIF($5, ''), $6, $7)
+	RETURNING ` + invitationColumns + `;
+	`
+
+	return scanInvitation(db.QueryRowContext(ctx, query,
+		inv.ID, inv.CodeHash, inv.Email, inv.Role, inv.CreatedBy, inv.ExpiresAt, TenantFromContext(ctx)))
+}
+
+// ListInvitations returns every invitation, including used, revoked and
+// expired ones, newest first.
+func ListInvitations(
+	ctx context.Context,
+	db *sql.DB,
+) ([]models.Invitation, error) {
+	query := `
+	SELECT ` + invitationColumns + `
+	FROM invitations
+	WHERE tenant_id = $1
+	ORDER BY created_at DESC, id;
+	`
+
+	rows, err := db.QueryContext(ctx, query, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []models.Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, *inv)
+	}
+
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation revokes an invitation that has not been used or revoked.
+func RevokeInvitation(
+	ctx context.Context,
+	db *sql.DB,
+	id string,
+) error {
+	query := `
+	UPDATE invitations
+	SET revoked_at = now()
+	WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL AND tenant_id = $2;
+	`
+
+	res, err := db.ExecContext(ctx, query, id, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// CreateUserWithInvitation creates user with the role of the invitation with
+// codeHash and marks the invitation used, in one transaction. It returns nil
+// and creates nothing when the invitation is unknown, used, revoked, expired
+// or for another email address, and ErrUserExists when the ID is taken.
+// Users invited by email have proven they own it, so their address starts
+// verified.
+func CreateUserWithInvitation(
+	ctx context.Context,
+	db *sql.DB,
+	user models.User,
+	codeHash string,
+) (*models.Invitation, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	SELECT ` + invitationColumns + `
+	FROM invitations
+	WHERE code_hash = $1
+		AND used_at IS NULL
+		AND revoked_at IS NULL
+		AND expires_at > now()
+		AND (email IS NULL OR lower(email) = lower($2))
+		AND tenant_id = $3
+	FOR UPDATE;
+	`
+
+	inv, err := scanInvitation(tx.QueryRowContext(ctx, query, codeHash, user.Email, TenantFromContext(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	query = `
+	INSERT INTO users (id, name, password_hash, role, email, display_name, avatar_url, locale, email_verified_at, tenant_id)
+	VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), CASE WHEN $9 THEN now() END, $10)
+	ON CONFLICT (id) DO NOTHING;
+	`
+	res, err := tx.ExecContext(ctx, query, user.ID, user.Name, user.PasswordHash, inv.Role,
+		user.Email, user.DisplayName, user.AvatarURL, user.Locale, inv.Email != "", TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return nil, ErrUserExists
+	}
+
+	query = `
+	UPDATE invitations
+	SET used_at = now(), used_by = $2
+	WHERE id = $1;
+	`
+	if _, err := tx.ExecContext(ctx, query, inv.ID, user.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return inv, nil
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+var invitationTestColumns = []string{"id", "code_hash", "email", "role", "created_by", "expires_at", "used_at", "used_by", "revoked_at", "created_at"}
+
+func TestCreateUserWithInvitation_UsesInvitedRole(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, code_hash").
+		WithArgs("hash", "carol@example.com", "default").
+		WillReturnRows(sqlmock.NewRows(invitationTestColumns).
+			AddRow("i1", "hash", "carol@example.com", models.RoleAdmin, "u1", now.Add(time.Hour), nil, "", nil, now))
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs("u3", "Carol", "pwd", models.RoleAdmin, "carol@example.com", "", "", "", true, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE invitations").
+		WithArgs("i1", "u3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	user := models.User{ID: "u3", Name: "Carol", PasswordHash: "pwd", Email: "carol@example.com"}
+	inv, err := CreateUserWithInvitation(context.Background(), db, user, "hash")
+	if err != nil {
+		t.Fatalf("CreateUserWithInvitation error: %v", err)
+	}
+	if inv == nil || inv.Role != models.RoleAdmin {
+		t.Fatalf("unexpected invitation: %+v", inv)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateUserWithInvitation_InvalidCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, code_hash").
+		WithArgs("hash", "", "default").
+		WillReturnRows(sqlmock.NewRows(invitationTestColumns))
+	mock.ExpectRollback()
+
+	inv, err := CreateUserWithInvitation(context.Background(), db, models.User{ID: "u3", Name: "Carol"}, "hash")
+	if err != nil || inv != nil {
+		t.Fatalf("CreateUserWithInvitation = %+v, %v; want nil, nil", inv, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevokeInvitation_AlreadyUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE invitations").
+		WithArgs("i1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := RevokeInvitation(context.Background(), db, "i1"); err != ErrInvitationNotFound {
+		t.Fatalf("expected ErrInvitationNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
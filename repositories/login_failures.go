@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"platform-go-challenge/models"
+)
+
+func GetLoginFailure(
+	ctx context.Context,
+	db *sql.DB,
+	key string,
+) (*models.LoginFailure, error) {
+	query := `
+	SELECT key, failures, last_failure_at, locked_until
+	FROM login_failures
+	WHERE key = $1;
+	`
+
+	var f models.LoginFailure
+	err := db.QueryRowContext(ctx, query, key).Scan(
+		&f.Key,
+		&f.Failures,
+		&f.LastFailureAt,
+		&f.LockedUntil,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// RecordLoginFailure increments the failure counter for key and returns the
+// new count. Counters whose last failure is older than resetBefore restart at 1.
+func RecordLoginFailure(
+	ctx context.Context,
+	db *sql.DB,
+	key string,
+	resetBefore time.Time,
+) (int, error) {
+	query := `
+	INSERT INTO login_failures (key, failures, last_failure_at)
+	VALUES ($1, 1, now())
+	ON CONFLICT (key) DO UPDATE SET
+		failures = CASE
+			WHEN login_failures.last_failure_at < $2 THEN 1
+			ELSE login_failures.failures + 1
+		END,
+		last_failure_at = now()
+	RETURNING failures;
+	`
+
+	var failures int
+	if err := db.QueryRowContext(ctx, query, key, resetBefore).Scan(&failures); err != nil {
+		return 0, err
+	}
+
+	return failures, nil
+}
+
+func LockLogin(
+	ctx context.Context,
+	db *sql.DB,
+	key string,
+	until time.Time,
+) error {
+	query := `
+	UPDATE login_failures
+	SET locked_until = $2
+	WHERE key = $1;
+	`
+
+	_, err := db.ExecContext(ctx, query, key, until)
+	return err
+}
+
+// ClearLoginFailures resets the counter and lock for key. It returns false
+// when there was nothing to clear.
+func ClearLoginFailures(
+	ctx context.Context,
+	db *sql.DB,
+	key string,
+) (bool, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM login_failures WHERE key = $1;`, key)
+	if err != nil {
+		return false, err
+	}
+
+	rows, _ := res.RowsAffected()
+	return rows > 0, nil
+}
+
+// PurgeLoginFailures deletes the counters whose last failure is older than
+// before and that are no longer locked, which would restart at 1 anyway. It
+// returns the number of rows removed.
+func PurgeLoginFailures(
+	ctx context.Context,
+	db *sql.DB,
+	before time.Time,
+) (int64, error) {
+	query := `
+	DELETE FROM login_failures
+	WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < now());
+	`
+
+	res, err := db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
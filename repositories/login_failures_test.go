@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetLoginFailure_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT key, failures, last_failure_at, locked_until").
+		WithArgs("user:u1").
+		WillReturnRows(sqlmock.NewRows([]string{"key", "failures", "last_failure_at", "locked_until"}))
+
+	failure, err := GetLoginFailure(context.Background(), db, "user:u1")
+	if err != nil {
+		t.Fatalf("GetLoginFailure error: %v", err)
+	}
+	if failure != nil {
+		t.Fatalf("expected nil failure, got %+v", failure)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRecordLoginFailure_ReturnsCount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	resetBefore := time.Now().Add(-time.Hour)
+	mock.ExpectQuery("INSERT INTO login_failures").
+		WithArgs("ip:10.0.0.1", resetBefore).
+		WillReturnRows(sqlmock.NewRows([]string{"failures"}).AddRow(3))
+
+	failures, err := RecordLoginFailure(context.Background(), db, "ip:10.0.0.1", resetBefore)
+	if err != nil {
+		t.Fatalf("RecordLoginFailure error: %v", err)
+	}
+	if failures != 3 {
+		t.Fatalf("failures = %d, want 3", failures)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLockLogin_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	until := time.Now().Add(time.Minute)
+	mock.ExpectExec("UPDATE login_failures").
+		WithArgs("user:u1", until).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := LockLogin(context.Background(), db, "user:u1", until); err != nil {
+		t.Fatalf("LockLogin error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestClearLoginFailures_NothingToClear(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs("user:u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	cleared, err := ClearLoginFailures(context.Background(), db, "user:u1")
+	if err != nil {
+		t.Fatalf("ClearLoginFailures error: %v", err)
+	}
+	if cleared {
+		t.Fatal("expected cleared = false")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeLoginFailures_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	before := time.Now().Add(-24 * time.Hour)
+	mock.ExpectExec("DELETE FROM login_failures").
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 7))
+
+	purged, err := PurgeLoginFailures(context.Background(), db, before)
+	if err != nil {
+		t.Fatalf("PurgeLoginFailures error: %v", err)
+	}
+	if purged != 7 {
+		t.Fatalf("purged = %d, want 7", purged)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
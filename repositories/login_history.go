@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"platform-go-challenge/models"
+)
+
+// RecordUserLogin adds login to the login history of its user and sets
+// their last login time to now.
+func RecordUserLogin(
+	ctx context.Context,
+	db *sql.DB,
+	login models.LoginEvent,
+) error {
+	query := `
+	WITH history AS (
+		INSERT INTO login_history (user_id, method, ip_address, user_agent)
+		SELECT $1, $2, NULLIF($3, ''), NULLIF($4, '')
+		WHERE $1 IN (SELECT id FROM users WHERE tenant_id = $5)
+	)
+	UPDATE users SET last_login_at = now()
+	WHERE id = $1 AND tenant_id = $5;
+	`
+
+	_, err := db.ExecContext(ctx, query, login.UserID, login.Method, login.IPAddress, login.UserAgent,
+		TenantFromContext(ctx))
+	return err
+}
+
+// ListLoginHistory returns every login of userID, newest first.
+func ListLoginHistory(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) ([]models.LoginEvent, error) {
+	query := `
+	SELECT id, user_id, method, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+	FROM login_history
+	WHERE user_id = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)
+	ORDER BY created_at DESC, id DESC;
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logins := []models.LoginEvent{}
+	for rows.Next() {
+		var l models.LoginEvent
+		if err := rows.Scan(&l.ID, &l.UserID, &l.Method, &l.IPAddress, &l.UserAgent, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		logins = append(logins, l)
+	}
+
+	return logins, rows.Err()
+}
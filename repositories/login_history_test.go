@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+func TestRecordUserLogin_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("INSERT INTO login_history .* UPDATE users SET last_login_at").
+		WithArgs("u1", models.LoginMethodPassword, "192.0.2.1", "", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = RecordUserLogin(context.Background(), db, models.LoginEvent{
+		UserID:    "u1",
+		Method:    models.LoginMethodPassword,
+		IPAddress: "192.0.2.1",
+	})
+	if err != nil {
+		t.Fatalf("RecordUserLogin error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestListLoginHistory_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM login_history").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "method", "ip_address", "user_agent", "created_at"}).
+			AddRow(2, "u1", models.LoginMethodOIDC, "", "", now).
+			AddRow(1, "u1", models.LoginMethodPassword, "192.0.2.1", "curl", now.Add(-time.Hour)))
+
+	logins, err := ListLoginHistory(context.Background(), db, "u1")
+	if err != nil {
+		t.Fatalf("ListLoginHistory error: %v", err)
+	}
+	if len(logins) != 2 || logins[0].Method != models.LoginMethodOIDC || logins[1].IPAddress != "192.0.2.1" {
+		t.Fatalf("unexpected logins: %+v", logins)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
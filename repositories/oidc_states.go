@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"platform-go-challenge/models"
+)
+
+// CreateOIDCLoginState stores a pending authorization request. Expired
+// requests that were never completed are cleaned up on the way.
+func CreateOIDCLoginState(
+	ctx context.Context,
+	db *sql.DB,
+	state models.OIDCLoginState,
+) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM oidc_login_states WHERE expires_at <= now();`); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO oidc_login_states (state_hash, nonce, code_verifier, link_user_id, session_mode, expires_at)
+	VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6);
+	`
+
+	_, err := db.ExecContext(ctx, query, state.StateHash, state.Nonce, state.CodeVerifier, state.LinkUserID, state.SessionMode, state.ExpiresAt)
+	return err
+}
+
+// ConsumeOIDCLoginState deletes the pending request with stateHash and
+// returns it, or nil when it does not exist or has expired. Each state can
+// be used once.
+func ConsumeOIDCLoginState(
+	ctx context.Context,
+	db *sql.DB,
+	stateHash string,
+) (*models.OIDCLoginState, error) {
+	query := `
+	DELETE FROM oidc_login_states
+	WHERE state_hash = $1
+	RETURNING state_hash, nonce, code_verifier, COALESCE(link_user_id, ''), session_mode, expires_at;
+	`
+
+	var s models.OIDCLoginState
+	err := db.QueryRowContext(ctx, query, stateHash).
+		Scan(&s.StateHash, &s.Nonce, &s.CodeVerifier, &s.LinkUserID, &s.SessionMode, &s.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !s.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+
+	return &s, nil
+}
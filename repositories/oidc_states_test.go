@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+var oidcStateColumns = []string{"state_hash", "nonce", "code_verifier", "link_user_id", "session_mode", "expires_at"}
+
+func TestCreateOIDCLoginState_PrunesExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expires := time.Now().Add(10 * time.Minute)
+	mock.ExpectExec("DELETE FROM oidc_login_states").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("INSERT INTO oidc_login_states").
+		WithArgs("hash", "nonce", "verifier", "", "", expires).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = CreateOIDCLoginState(context.Background(), db, models.OIDCLoginState{
+		StateHash:    "hash",
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+		ExpiresAt:    expires,
+	})
+	if err != nil {
+		t.Fatalf("CreateOIDCLoginState error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestConsumeOIDCLoginState(t *testing.T) {
+	cases := map[string]struct {
+		rows *sqlmock.Rows
+		err  error
+		want bool
+	}{
+		"pending": {
+			rows: sqlmock.NewRows(oidcStateColumns).AddRow("hash", "nonce", "verifier", "u1", "", time.Now().Add(time.Minute)),
+			want: true,
+		},
+		"expired": {
+			rows: sqlmock.NewRows(oidcStateColumns).AddRow("hash", "nonce", "verifier", "", "", time.Now().Add(-time.Minute)),
+		},
+		"unknown or already used": {
+			err: sql.ErrNoRows,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("sqlmock.New error: %v", err)
+			}
+			defer db.Close()
+
+			q := mock.ExpectQuery("DELETE FROM oidc_login_states").WithArgs("hash")
+			if tc.err != nil {
+				q.WillReturnError(tc.err)
+			} else {
+				q.WillReturnRows(tc.rows)
+			}
+
+			state, err := ConsumeOIDCLoginState(context.Background(), db, "hash")
+			if err != nil {
+				t.Fatalf("ConsumeOIDCLoginState error: %v", err)
+			}
+			if (state != nil) != tc.want {
+				t.Fatalf("ConsumeOIDCLoginState = %+v, want found=%v", state, tc.want)
+			}
+			if state != nil && state.LinkUserID != "u1" {
+				t.Fatalf("LinkUserID = %q, want u1", state.LinkUserID)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
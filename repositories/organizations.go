@@ -0,0 +1,378 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"platform-go-challenge/models"
+)
+
+var (
+	ErrOrganizationNotFound       = errors.New("organization not found")
+	ErrOrganizationExists         = errors.New("organization already exists")
+	ErrOrganizationMemberNotFound = errors.New("organization member not found")
+	ErrOrganizationMemberExists   = errors.New("user is already a member of the organization")
+	ErrLastOrganizationOwner      = errors.New("organization must keep at least one owner")
+)
+
+const organizationColumns = `id, name, created_at, updated_at`
+
+func scanOrganization(row rowScanner) (*models.Organization, error) {
+	var org models.Organization
+	if err := row.Scan(&org.ID, &org.Name, &org.CreatedAt, &org.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	return &org, nil
+}
+
+// CreateOrganization creates org with ownerID as its first owner, in one
+// transaction. It returns ErrOrganizationExists when the ID is taken.
+func CreateOrganization(
+	ctx context.Context,
+	db *sql.DB,
+	org models.Organization,
+	ownerID string,
+) (*models.Organization, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO organizations (id, name, tenant_id)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (id) DO NOTHING
+	RETURNING ` + organizationColumns + `;
+	`
+
+	created, err := scanOrganization(tx.QueryRowContext(ctx, query, org.ID, org.Name, TenantFromContext(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrganizationExists
+		}
+		return nil, err
+	}
+
+	query = `
+	INSERT INTO organization_members (org_id, user_id, role, tenant_id)
+	VALUES ($1, $2, $3, $4);
+	`
+
+	_, err = tx.ExecContext(ctx, query, created.ID, ownerID, models.OrgRoleOwner, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	created.Role = models.OrgRoleOwner
+	return created, nil
+}
+
+func GetOrganization(
+	ctx context.Context,
+	db *sql.DB,
+	orgID string,
+) (*models.Organization, error) {
+	query := `
+	SELECT ` + organizationColumns + `
+	FROM organizations
+	WHERE id = $1 AND tenant_id = $2;
+	`
+
+	org, err := scanOrganization(db.QueryRowContext(ctx, query, orgID, TenantFromContext(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// ListOrganizations returns every organization. With a userID it returns
+// only the organizations the user belongs to, together with their role.
+func ListOrganizations(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) ([]models.Organization, error) {
+	query := `
+	SELECT o.id, o.name, o.created_at, o.updated_at, COALESCE(m.role, '')
+	FROM organizations o
+	LEFT JOIN organization_members m ON m.org_id = o.id AND m.user_id = $1
+	WHERE o.tenant_id = $2 AND ($1 = '' OR m.user_id IS NOT NULL)
+	ORDER BY o.name, o.id;
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orgs := []models.Organization{}
+	for rows.Next() {
+		var o models.Organization
+		if err := rows.Scan(&o.ID, &o.Name, &o.CreatedAt, &o.UpdatedAt, &o.Role); err != nil {
+			return nil, err
+		}
+		orgs = append(orgs, o)
+	}
+
+	return orgs, rows.Err()
+}
+
+func UpdateOrganization(
+	ctx context.Context,
+	db *sql.DB,
+	orgID, name string,
+) (*models.Organization, error) {
+	query := `
+	UPDATE organizations
+	SET name = $2, updated_at = now()
+	WHERE id = $1 AND tenant_id = $3
+	RETURNING ` + organizationColumns + `;
+	`
+
+	org, err := scanOrganization(db.QueryRowContext(ctx, query, orgID, name, TenantFromContext(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// DeleteOrganization removes an organization together with its memberships
+// and assets.
+func DeleteOrganization(
+	ctx context.Context,
+	db *sql.DB,
+	orgID string,
+) error {
+	query := `
+	DELETE FROM organizations
+	WHERE id = $1 AND tenant_id = $2;
+	`
+
+	res, err := db.ExecContext(ctx, query, orgID, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrOrganizationNotFound
+	}
+
+	return nil
+}
+
+func GetOrganizationMember(
+	ctx context.Context,
+	db *sql.DB,
+	orgID, userID string,
+) (*models.OrganizationMember, error) {
+	query := `
+	SELECT org_id, user_id, role, created_at
+	FROM organization_members
+	WHERE org_id = $1 AND user_id = $2 AND tenant_id = $3;
+	`
+
+	var m models.OrganizationMember
+	err := db.QueryRowContext(ctx, query, orgID, userID, TenantFromContext(ctx)).Scan(&m.OrgID, &m.UserID, &m.Role, &m.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func ListOrganizationMembers(
+	ctx context.Context,
+	db *sql.DB,
+	orgID string,
+) ([]models.OrganizationMember, error) {
+	query := `
+	SELECT m.org_id, m.user_id, u.name, m.role, m.created_at
+	FROM organization_members m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.org_id = $1 AND m.tenant_id = $2
+	ORDER BY m.created_at, m.user_id;
+	`
+
+	rows, err := db.QueryContext(ctx, query, orgID, TenantFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.OrganizationMember{}
+	for rows.Next() {
+		var m models.OrganizationMember
+		if err := rows.Scan(&m.OrgID, &m.UserID, &m.Name, &m.Role, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddOrganizationMember returns ErrOrganizationMemberExists when the user
+// already belongs to the organization. The organization and the user must
+// both be in the tenant.
+func AddOrganizationMember(
+	ctx context.Context,
+	db *sql.DB,
+	member models.OrganizationMember,
+) (*models.OrganizationMember, error) {
+	query := `
+	INSERT INTO organization_members (org_id, user_id, role, tenant_id)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (org_id, user_id) DO NOTHING
+	RETURNING org_id, user_id, role, created_at;
+	`
+
+	var m models.OrganizationMember
+	err := db.QueryRowContext(ctx, query, member.OrgID, member.UserID, member.Role, TenantFromContext(ctx)).
+		Scan(&m.OrgID, &m.UserID, &m.Role, &m.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrganizationMemberExists
+		}
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// UpdateOrganizationMemberRole changes the role of a member. Demoting the
+// last owner fails with ErrLastOrganizationOwner.
+func UpdateOrganizationMemberRole(
+	ctx context.Context,
+	db *sql.DB,
+	orgID, userID, role string,
+) (*models.OrganizationMember, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if role != models.OrgRoleOwner {
+		if err := checkNotLastOwner(ctx, tx, orgID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+	UPDATE organization_members
+	SET role = $3
+	WHERE org_id = $1 AND user_id = $2 AND tenant_id = $4
+	RETURNING org_id, user_id, role, created_at;
+	`
+
+	var m models.OrganizationMember
+	err = tx.QueryRowContext(ctx, query, orgID, userID, role, TenantFromContext(ctx)).
+		Scan(&m.OrgID, &m.UserID, &m.Role, &m.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOrganizationMemberNotFound
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// RemoveOrganizationMember removes a user from an organization. Removing the
+// last owner fails with ErrLastOrganizationOwner.
+func RemoveOrganizationMember(
+	ctx context.Context,
+	db *sql.DB,
+	orgID, userID string,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkNotLastOwner(ctx, tx, orgID, userID); err != nil {
+		return err
+	}
+
+	query := `
+	DELETE FROM organization_members
+	WHERE org_id = $1 AND user_id = $2 AND tenant_id = $3;
+	`
+
+	res, err := tx.ExecContext(ctx, query, orgID, userID, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrOrganizationMemberNotFound
+	}
+
+	return tx.Commit()
+}
+
+// checkNotLastOwner returns ErrLastOrganizationOwner when userID is the only
+// owner of orgID. The owners stay locked until tx ends, so two owners cannot
+// step down at the same time.
+func checkNotLastOwner(
+	ctx context.Context,
+	tx *sql.Tx,
+	orgID, userID string,
+) error {
+	query := `
+	SELECT user_id
+	FROM organization_members
+	WHERE org_id = $1 AND role = 'owner' AND tenant_id = $2
+	FOR UPDATE;
+	`
+
+	rows, err := tx.QueryContext(ctx, query, orgID, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var owners []string
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return err
+		}
+		owners = append(owners, owner)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(owners) == 1 && owners[0] == userID {
+		return ErrLastOrganizationOwner
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+func TestCreateOrganization_Exists(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO organizations").
+		WithArgs("acme", "Acme", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at"}))
+	mock.ExpectRollback()
+
+	_, err = CreateOrganization(context.Background(), db, models.Organization{ID: "acme", Name: "Acme"}, "u1")
+	if !errors.Is(err, ErrOrganizationExists) {
+		t.Fatalf("err = %v, want ErrOrganizationExists", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestListOrganizations_OfUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("FROM organizations o").
+		WithArgs("u2", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "role"}).
+			AddRow("acme", "Acme", now, now, models.OrgRoleMember))
+
+	orgs, err := ListOrganizations(context.Background(), db, "u2")
+	if err != nil {
+		t.Fatalf("ListOrganizations error: %v", err)
+	}
+	if len(orgs) != 1 || orgs[0].ID != "acme" || orgs[0].Role != models.OrgRoleMember {
+		t.Fatalf("unexpected organizations: %+v", orgs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateOrganizationMemberRole_LastOwner(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id").
+		WithArgs("acme", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1"))
+	mock.ExpectRollback()
+
+	_, err = UpdateOrganizationMemberRole(context.Background(), db, "acme", "u1", models.OrgRoleAdmin)
+	if !errors.Is(err, ErrLastOrganizationOwner) {
+		t.Fatalf("err = %v, want ErrLastOrganizationOwner", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRemoveOrganizationMember_OtherOwnerLeft(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT user_id").
+		WithArgs("acme", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1").AddRow("u3"))
+	mock.ExpectExec("DELETE FROM organization_members").
+		WithArgs("acme", "u1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := RemoveOrganizationMember(context.Background(), db, "acme", "u1"); err != nil {
+		t.Fatalf("RemoveOrganizationMember error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
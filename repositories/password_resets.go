@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CreatePasswordResetToken returns ErrUserNotFound when the user is not in
+// the tenant.
+func CreatePasswordResetToken(
+	ctx context.Context,
+	db *sql.DB,
+	tokenHash, userID string,
+	expiresAt time.Time,
+) error {
+	query := `
+	INSERT INTO password_reset_tokens (token_hash, user_id, expires_at)
+	SELECT $1, $2, $3::timestamp
+	WHERE $2 IN (SELECT id FROM users WHERE tenant_id = $4);
+	`
+
+	res, err := db.ExecContext(ctx, query, tokenHash, userID, expiresAt, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ConsumePasswordResetToken marks an unused, unexpired reset token as used and
+// returns the user it belongs to, or "" when the token is not valid.
+func ConsumePasswordResetToken(
+	ctx context.Context,
+	db *sql.DB,
+	tokenHash string,
+) (string, error) {
+	query := `
+	UPDATE password_reset_tokens
+	SET used_at = now()
+	WHERE token_hash = $1
+		AND used_at IS NULL
+		AND expires_at > now()
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)
+	RETURNING user_id;
+	`
+
+	var userID string
+	err := db.QueryRowContext(ctx, query, tokenHash, TenantFromContext(ctx)).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// InvalidatePasswordResetTokens marks every outstanding reset token of userID
+// as used, e.g. once the password has been changed.
+func InvalidatePasswordResetTokens(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) error {
+	query := `
+	UPDATE password_reset_tokens
+	SET used_at = now()
+	WHERE user_id = $1 AND used_at IS NULL
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $2);
+	`
+
+	_, err := db.ExecContext(ctx, query, userID, TenantFromContext(ctx))
+	return err
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreatePasswordResetToken_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expires := time.Now().Add(time.Hour)
+	mock.ExpectExec("INSERT INTO password_reset_tokens").
+		WithArgs("hash", "u1", expires, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := CreatePasswordResetToken(context.Background(), db, "hash", "u1", expires); err != nil {
+		t.Fatalf("CreatePasswordResetToken error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestConsumePasswordResetToken_Valid(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE password_reset_tokens").
+		WithArgs("hash", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow("u1"))
+
+	userID, err := ConsumePasswordResetToken(context.Background(), db, "hash")
+	if err != nil {
+		t.Fatalf("ConsumePasswordResetToken error: %v", err)
+	}
+	if userID != "u1" {
+		t.Fatalf("userID = %q, want u1", userID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestConsumePasswordResetToken_Invalid(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE password_reset_tokens").
+		WithArgs("hash", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}))
+
+	userID, err := ConsumePasswordResetToken(context.Background(), db, "hash")
+	if err != nil {
+		t.Fatalf("ConsumePasswordResetToken error: %v", err)
+	}
+	if userID != "" {
+		t.Fatalf("userID = %q, want empty", userID)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestInvalidatePasswordResetTokens_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE password_reset_tokens").
+		WithArgs("u1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	if err := InvalidatePasswordResetTokens(context.Background(), db, "u1"); err != nil {
+		t.Fatalf("InvalidatePasswordResetTokens error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
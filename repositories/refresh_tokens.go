@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"platform-go-challenge/models"
+)
+
+// CreateRefreshToken returns ErrUserNotFound when the user is not in the
+// tenant.
+func CreateRefreshToken(
+	ctx context.Context,
+	db *sql.DB,
+	token models.RefreshToken,
+) error {
+	query := `
+	INSERT INTO refresh_tokens (token_hash, user_id, family_id, scope, expires_at, org_id)
+	SELECT $1, $2, $3, $4, $5::timestamp, $6
+	WHERE $2 IN (SELECT id FROM users WHERE tenant_id = $7);
+	`
+
+	res, err := db.ExecContext(
+		ctx,
+		query,
+		token.TokenHash,
+		token.UserID,
+		token.FamilyID,
+		token.Scope,
+		token.ExpiresAt,
+		token.OrgID,
+		TenantFromContext(ctx),
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func GetRefreshToken(
+	ctx context.Context,
+	db *sql.DB,
+	tokenHash string,
+) (*models.RefreshToken, error) {
+	query := `
+	SELECT token_hash, user_id, family_id, scope, expires_at, rotated_at, revoked_at, created_at
+	FROM refresh_tokens
+	WHERE token_hash = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2);
+	`
+
+	var t models.RefreshToken
+	err := db.QueryRowContext(ctx, query, tokenHash, TenantFromContext(ctx)).Scan(
+		&t.TokenHash,
+		&t.UserID,
+		&t.FamilyID,
+		&t.Scope,
+		&t.ExpiresAt,
+		&t.RotatedAt,
+		&t.RevokedAt,
+		&t.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// ConsumeRefreshToken marks an active refresh token as rotated and returns it.
+// It returns nil when the token does not exist, is expired, or was already
+// rotated or revoked; the check and update happen in a single statement so a
+// token can only ever be consumed once.
+func ConsumeRefreshToken(
+	ctx context.Context,
+	db *sql.DB,
+	tokenHash string,
+) (*models.RefreshToken, error) {
+	query := `
+	UPDATE refresh_tokens
+	SET rotated_at = now()
+	WHERE token_hash = $1
+		AND rotated_at IS NULL
+		AND revoked_at IS NULL
+		AND expires_at > now()
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $2)
+	RETURNING token_hash, user_id, family_id, scope, expires_at, rotated_at, created_at, org_id;
+	`
+
+	var t models.RefreshToken
+	err := db.QueryRowContext(ctx, query, tokenHash, TenantFromContext(ctx)).Scan(
+		&t.TokenHash,
+		&t.UserID,
+		&t.FamilyID,
+		&t.Scope,
+		&t.ExpiresAt,
+		&t.RotatedAt,
+		&t.CreatedAt,
+		&t.OrgID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func RevokeRefreshTokenFamily(
+	ctx context.Context,
+	db *sql.DB,
+	familyID string,
+) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = now()
+	WHERE family_id = $1 AND revoked_at IS NULL
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $2);
+	`
+
+	_, err := db.ExecContext(ctx, query, familyID, TenantFromContext(ctx))
+	return err
+}
+
+func RevokeUserRefreshTokens(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = now()
+	WHERE user_id = $1 AND revoked_at IS NULL
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $2);
+	`
+
+	_, err := db.ExecContext(ctx, query, userID, TenantFromContext(ctx))
+	return err
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"platform-go-challenge/models"
+)
+
+func TestCreateRefreshToken_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expires := time.Now().Add(time.Hour)
+	mock.ExpectExec("INSERT INTO refresh_tokens").
+		WithArgs("hash", "u1", "fam1", "favourites:read", expires, "acme", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = CreateRefreshToken(context.Background(), db, models.RefreshToken{
+		TokenHash: "hash",
+		UserID:    "u1",
+		FamilyID:  "fam1",
+		Scope:     "favourites:read",
+		ExpiresAt: expires,
+		OrgID:     "acme",
+	})
+	if err != nil {
+		t.Fatalf("CreateRefreshToken error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestConsumeRefreshToken_Active(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("UPDATE refresh_tokens").
+		WithArgs("hash", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "family_id", "scope", "expires_at", "rotated_at", "created_at", "org_id"}).
+			AddRow("hash", "u1", "fam1", "favourites:read", now.Add(time.Hour), now, now, "acme"))
+
+	token, err := ConsumeRefreshToken(context.Background(), db, "hash")
+	if err != nil {
+		t.Fatalf("ConsumeRefreshToken error: %v", err)
+	}
+	if token == nil || token.FamilyID != "fam1" || token.Scope != "favourites:read" || token.OrgID != "acme" {
+		t.Fatalf("unexpected token: %+v", token)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestConsumeRefreshToken_AlreadyUsed(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE refresh_tokens").
+		WithArgs("hash", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "family_id", "scope", "expires_at", "rotated_at", "created_at"}))
+
+	token, err := ConsumeRefreshToken(context.Background(), db, "hash")
+	if err != nil {
+		t.Fatalf("ConsumeRefreshToken error: %v", err)
+	}
+	if token != nil {
+		t.Fatalf("expected nil token, got %+v", token)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetRefreshToken_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT token_hash").
+		WithArgs("missing", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"token_hash", "user_id", "family_id", "expires_at", "rotated_at", "revoked_at", "created_at"}))
+
+	token, err := GetRefreshToken(context.Background(), db, "missing")
+	if err != nil {
+		t.Fatalf("GetRefreshToken error: %v", err)
+	}
+	if token != nil {
+		t.Fatalf("expected nil token, got %+v", token)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevokeRefreshTokenFamily_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE refresh_tokens").
+		WithArgs("fam1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if err := RevokeRefreshTokenFamily(context.Background(), db, "fam1"); err != nil {
+		t.Fatalf("RevokeRefreshTokenFamily error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
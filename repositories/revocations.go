@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"platform-go-challenge/models"
+)
+
+func RevokeToken(
+	ctx context.Context,
+	db *sql.DB,
+	jti string,
+	userID *string,
+	expiresAt time.Time,
+) error {
+	query := `
+	INSERT INTO revoked_tokens (jti, user_id, expires_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (jti) DO NOTHING;
+	`
+
+	_, err := db.ExecContext(ctx, query, jti, userID, expiresAt)
+	return err
+}
+
+// RevokeUserTokens rejects every access token for userID issued until before.
+// Users outside the tenant are left alone.
+func RevokeUserTokens(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+	before time.Time,
+) error {
+	query := `
+	INSERT INTO user_token_revocations (user_id, revoked_before)
+	SELECT $1, $2::timestamp
+	WHERE $1 IN (SELECT id FROM users WHERE tenant_id = $3)
+	ON CONFLICT (user_id)
+	DO UPDATE SET revoked_before = GREATEST(user_token_revocations.revoked_before, EXCLUDED.revoked_before);
+	`
+
+	_, err := db.ExecContext(ctx, query, userID, before, TenantFromContext(ctx))
+	return err
+}
+
+func ListRevokedTokens(
+	ctx context.Context,
+	db *sql.DB,
+) ([]models.RevokedToken, error) {
+	query := `
+	SELECT jti, user_id, expires_at, revoked_at
+	FROM revoked_tokens
+	WHERE expires_at > now();
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.RevokedToken
+	for rows.Next() {
+		var t models.RevokedToken
+		if err := rows.Scan(&t.JTI, &t.UserID, &t.ExpiresAt, &t.RevokedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func ListUserTokenRevocations(
+	ctx context.Context,
+	db *sql.DB,
+) ([]models.UserTokenRevocation, error) {
+	query := `
+	SELECT user_id, revoked_before
+	FROM user_token_revocations;
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revocations []models.UserTokenRevocation
+	for rows.Next() {
+		var u models.UserTokenRevocation
+		if err := rows.Scan(&u.UserID, &u.RevokedBefore); err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, u)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revocations, nil
+}
+
+// PurgeExpiredRevocations deletes revoked tokens that have expired and user
+// revocations older than userCutoff, after which no token they cover can
+// still be valid. It returns the number of rows removed.
+func PurgeExpiredRevocations(
+	ctx context.Context,
+	db *sql.DB,
+	userCutoff time.Time,
+) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at <= now();`)
+	if err != nil {
+		return 0, err
+	}
+	tokens, _ := res.RowsAffected()
+
+	res, err = db.ExecContext(ctx, `DELETE FROM user_token_revocations WHERE revoked_before < $1;`, userCutoff)
+	if err != nil {
+		return tokens, err
+	}
+	users, _ := res.RowsAffected()
+
+	return tokens + users, nil
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRevokeToken_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expires := time.Now().Add(time.Hour)
+	userID := "u1"
+	mock.ExpectExec("INSERT INTO revoked_tokens").
+		WithArgs("jti1", &userID, expires).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := RevokeToken(context.Background(), db, "jti1", &userID, expires); err != nil {
+		t.Fatalf("RevokeToken error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRevokeUserTokens_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	before := time.Now()
+	mock.ExpectExec("INSERT INTO user_token_revocations").
+		WithArgs("u1", before, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := RevokeUserTokens(context.Background(), db, "u1", before); err != nil {
+		t.Fatalf("RevokeUserTokens error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestListRevokedTokens_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mock.ExpectQuery("SELECT jti, user_id, expires_at, revoked_at").
+		WillReturnRows(sqlmock.NewRows([]string{"jti", "user_id", "expires_at", "revoked_at"}).
+			AddRow("jti1", "u1", now.Add(time.Hour), now).
+			AddRow("jti2", nil, now.Add(time.Hour), now))
+
+	tokens, err := ListRevokedTokens(context.Background(), db)
+	if err != nil {
+		t.Fatalf("ListRevokedTokens error: %v", err)
+	}
+	if len(tokens) != 2 || tokens[0].JTI != "jti1" || tokens[1].UserID != nil {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestListUserTokenRevocations_DBError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT user_id, revoked_before").
+		WillReturnError(sql.ErrConnDone)
+
+	if _, err := ListUserTokenRevocations(context.Background(), db); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeExpiredRevocations_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	cutoff := time.Now().Add(-time.Hour)
+	mock.ExpectExec("DELETE FROM revoked_tokens").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM user_token_revocations").
+		WithArgs(cutoff).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	purged, err := PurgeExpiredRevocations(context.Background(), db, cutoff)
+	if err != nil {
+		t.Fatalf("PurgeExpiredRevocations error: %v", err)
+	}
+	if purged != 4 {
+		t.Fatalf("purged = %d, want 4", purged)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DefaultTenant owns every row created before tenants existed, and is the
+// tenant of contexts that were not given one.
+const DefaultTenant = "default"
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of ctx in which every repository function only
+// reads and writes the rows of tenantID.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set with WithTenant, or DefaultTenant.
+//
+// Users, assets, favourites, organizations and invitations carry their
+// tenant_id. Tables of per-user data are limited to the users of the tenant.
+// Login failure counters, OIDC login states and the token denylist are keyed
+// by values that are unique across tenants, and are read by jobs that serve
+// every tenant, so they are not filtered.
+func TenantFromContext(ctx context.Context) string {
+	if tenantID, ok := ctx.Value(tenantContextKey{}).(string); ok && tenantID != "" {
+		return tenantID
+	}
+	return DefaultTenant
+}
+
+// ListTenants returns the IDs of every tenant, for jobs that run for each.
+func ListTenants(
+	ctx context.Context,
+	db *sql.DB,
+) ([]string, error) {
+	query := `
+	SELECT id
+	FROM tenants
+	ORDER BY id;
+	`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tenants []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, id)
+	}
+
+	return tenants, rows.Err()
+}
+
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// checkUserInTenant returns ErrUserNotFound unless userID belongs to the
+// tenant of ctx, for writes whose own statement cannot filter by it.
+func checkUserInTenant(
+	ctx context.Context,
+	q rowQueryer,
+	userID string,
+) error {
+	query := `
+	SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND tenant_id = $2);
+	`
+
+	var exists bool
+	if err := q.QueryRowContext(ctx, query, userID, TenantFromContext(ctx)).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+func TestTenantFromContext_DefaultsToDefaultTenant(t *testing.T) {
+	if got := TenantFromContext(context.Background()); got != DefaultTenant {
+		t.Fatalf("tenant = %q, want %q", got, DefaultTenant)
+	}
+	if got := TenantFromContext(WithTenant(context.Background(), "globex")); got != "globex" {
+		t.Fatalf("tenant = %q, want globex", got)
+	}
+}
+
+func TestGetUserByID_OtherTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`WHERE id = \$1 AND tenant_id = \$2`).
+		WithArgs("u1", "globex").
+		WillReturnRows(sqlmock.NewRows(userTestColumns))
+
+	user, err := GetUserByID(WithTenant(context.Background(), "globex"), db, "u1")
+	if err != nil {
+		t.Fatalf("GetUserByID error: %v", err)
+	}
+	if user != nil {
+		t.Fatalf("user of another tenant returned: %+v", user)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAddFavourite_AssetOfOtherTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectUserTx(mock, "u1", models.RoleMember)
+	mock.ExpectExec(`FROM assets a\s+WHERE a.id = \$2 AND a.tenant_id = \$4`).
+		WithArgs("u1", "a1", nil, "globex", "u1", false).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err = AddFavourite(WithTenant(userContext("u1"), "globex"), db, "u1", "a1", nil)
+	if !errors.Is(err, ErrAssetNotFound) {
+		t.Fatalf("err = %v, want ErrAssetNotFound", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateAPIKey_UserOfOtherTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`INSERT INTO api_keys .* WHERE \$2 IN \(SELECT id FROM users WHERE tenant_id = \$8\)`).
+		WithArgs(sqlmock.AnyArg(), "u1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "globex").
+		WillReturnRows(sqlmock.NewRows(apiKeyTestColumns))
+
+	_, err = CreateAPIKey(WithTenant(context.Background(), "globex"), db, models.APIKey{
+		ID:      "k1",
+		UserID:  "u1",
+		Name:    "etl",
+		Prefix:  "pgc_abcd1234",
+		KeyHash: "hash",
+		Scopes:  []string{"favourites:read"},
+	})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("err = %v, want ErrUserNotFound", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestListOrganizations_OfTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(`WHERE o.tenant_id = \$2`).
+		WithArgs("", "globex").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "created_at", "updated_at", "role"}))
+
+	orgs, err := ListOrganizations(WithTenant(context.Background(), "globex"), db, "")
+	if err != nil {
+		t.Fatalf("ListOrganizations error: %v", err)
+	}
+	if len(orgs) != 0 {
+		t.Fatalf("unexpected organizations: %+v", orgs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
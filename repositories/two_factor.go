@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"platform-go-challenge/models"
+)
+
+// GetTOTPEnrollment returns the TOTP enrollment of userID, or nil when the
+// user never started one.
+func GetTOTPEnrollment(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) (*models.TOTPEnrollment, error) {
+	query := `
+	SELECT user_id, secret, enabled_at, last_used_step
+	FROM user_totp
+	WHERE user_id = $1 AND user_id IN (SELECT id FROM users WHERE tenant_id = $2);
+	`
+
+	var e models.TOTPEnrollment
+	err := db.QueryRowContext(ctx, query, userID, TenantFromContext(ctx)).
+		Scan(&e.UserID, &e.Secret, &e.EnabledAt, &e.LastUsedStep)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// SaveTOTPSecret stores a new, not yet enabled secret for userID, replacing
+// any pending one. Enabled enrollments are left untouched. It returns
+// ErrUserNotFound when the user is not in the tenant.
+func SaveTOTPSecret(
+	ctx context.Context,
+	db *sql.DB,
+	userID, secret string,
+) error {
+	query := `
+	INSERT INTO user_totp (user_id, secret)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE
+	SET secret = EXCLUDED.secret, last_used_step = NULL, created_at = now()
+	WHERE user_totp.enabled_at IS NULL;
+	`
+
+	if err := checkUserInTenant(ctx, db, userID); err != nil {
+		return err
+	}
+
+	_, err := db.ExecContext(ctx, query, userID, secret)
+	return err
+}
+
+// EnableTOTP activates the pending enrollment of userID, recording step as
+// the last code used, and replaces the user's recovery codes with
+// codeHashes, in one transaction. It returns sql.ErrNoRows when there is no
+// pending enrollment.
+func EnableTOTP(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+	step int64,
+	codeHashes []string,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE user_totp
+	SET enabled_at = now(), last_used_step = $2
+	WHERE user_id = $1 AND enabled_at IS NULL
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $3);
+	`
+
+	result, err := tx.ExecContext(ctx, query, userID, step, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1;`, userID); err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO user_recovery_codes (code_hash, user_id)
+	VALUES ($1, $2);
+	`
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, query, hash, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records step as used for userID. It returns false when a code
+// from the same or a later step was already used, i.e. on replay.
+func UseTOTPStep(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+	step int64,
+) (bool, error) {
+	query := `
+	UPDATE user_totp
+	SET last_used_step = $2
+	WHERE user_id = $1
+		AND enabled_at IS NOT NULL
+		AND (last_used_step IS NULL OR last_used_step < $2)
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $3);
+	`
+
+	result, err := db.ExecContext(ctx, query, userID, step, TenantFromContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// ConsumeRecoveryCode marks an unused recovery code of userID as used. It
+// returns false when the code does not exist or was already used.
+func ConsumeRecoveryCode(
+	ctx context.Context,
+	db *sql.DB,
+	userID, codeHash string,
+) (bool, error) {
+	query := `
+	UPDATE user_recovery_codes
+	SET used_at = now()
+	WHERE code_hash = $1 AND user_id = $2 AND used_at IS NULL
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $3);
+	`
+
+	result, err := db.ExecContext(ctx, query, codeHash, userID, TenantFromContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
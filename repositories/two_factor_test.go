@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetTOTPEnrollment_Found(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	enabled := time.Now()
+	mock.ExpectQuery("SELECT user_id, secret, enabled_at, last_used_step").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "secret", "enabled_at", "last_used_step"}).
+			AddRow("u1", "SECRET", enabled, int64(42)))
+
+	e, err := GetTOTPEnrollment(context.Background(), db, "u1")
+	if err != nil {
+		t.Fatalf("GetTOTPEnrollment error: %v", err)
+	}
+	if e == nil || e.Secret != "SECRET" || e.EnabledAt == nil || e.LastUsedStep == nil || *e.LastUsedStep != 42 {
+		t.Fatalf("unexpected enrollment: %+v", e)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestGetTOTPEnrollment_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT user_id, secret, enabled_at, last_used_step").
+		WithArgs("u1", "default").
+		WillReturnError(sql.ErrNoRows)
+
+	e, err := GetTOTPEnrollment(context.Background(), db, "u1")
+	if err != nil || e != nil {
+		t.Fatalf("GetTOTPEnrollment = %+v, %v; want nil, nil", e, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnableTOTP_NoPendingEnrollment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp").
+		WithArgs("u1", int64(7), "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := EnableTOTP(context.Background(), db, "u1", 7, []string{"h1"}); err != sql.ErrNoRows {
+		t.Fatalf("EnableTOTP error = %v, want sql.ErrNoRows", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUseTOTPStep_Replay(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE user_totp").
+		WithArgs("u1", int64(7), "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ok, err := UseTOTPStep(context.Background(), db, "u1", 7)
+	if err != nil || ok {
+		t.Fatalf("UseTOTPStep = %v, %v; want false, nil", ok, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnableTOTP_StoresRecoveryCodes(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp").
+		WithArgs("u1", int64(7), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user_recovery_codes").
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("INSERT INTO user_recovery_codes").
+		WithArgs("h1", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_recovery_codes").
+		WithArgs("h2", "u1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := EnableTOTP(context.Background(), db, "u1", 7, []string{"h1", "h2"}); err != nil {
+		t.Fatalf("EnableTOTP error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEnableTOTP_RollsBackWhenRecoveryCodesFail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE user_totp").
+		WithArgs("u1", int64(7), "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user_recovery_codes").
+		WithArgs("u1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO user_recovery_codes").
+		WithArgs("h1", "u1").
+		WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	if err := EnableTOTP(context.Background(), db, "u1", 7, []string{"h1"}); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestConsumeRecoveryCode(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE user_recovery_codes").
+		WithArgs("h1", "u1", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ok, err := ConsumeRecoveryCode(context.Background(), db, "u1", "h1")
+	if err != nil || !ok {
+		t.Fatalf("ConsumeRecoveryCode = %v, %v; want true, nil", ok, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"platform-go-challenge/models"
+)
+
+// GetUserIdentity returns the local link of the provider account subject at
+// issuer, or nil when the account was never linked.
+func GetUserIdentity(
+	ctx context.Context,
+	db *sql.DB,
+	issuer, subject string,
+) (*models.UserIdentity, error) {
+	query := `
+	SELECT issuer, subject, user_id, COALESCE(email, ''), created_at, last_login_at
+	FROM user_identities
+	WHERE issuer = $1 AND subject = $2
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $3);
+	`
+
+	var i models.UserIdentity
+	err := db.QueryRowContext(ctx, query, issuer, subject, TenantFromContext(ctx)).
+		Scan(&i.Issuer, &i.Subject, &i.UserID, &i.Email, &i.CreatedAt, &i.LastLoginAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+// CreateUserIdentity links a provider account to an existing user. It returns
+// ErrUserNotFound when the user is not in the tenant.
+func CreateUserIdentity(
+	ctx context.Context,
+	db *sql.DB,
+	identity models.UserIdentity,
+) error {
+	query := `
+	INSERT INTO user_identities (issuer, subject, user_id, email, last_login_at)
+	SELECT $1, $2, $3, NULLIF($4, ''), now()
+	WHERE $3 IN (SELECT id FROM users WHERE tenant_id = $5);
+	`
+
+	res, err := db.ExecContext(ctx, query, identity.Issuer, identity.Subject, identity.UserID, identity.Email,
+		TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// CreateUserWithIdentity provisions a new user linked to a provider account,
+// so that either both rows exist or neither does. The user joins the tenant.
+func CreateUserWithIdentity(
+	ctx context.Context,
+	db *sql.DB,
+	user models.User,
+	identity models.UserIdentity,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleMember
+	}
+
+	query := `
+	INSERT INTO users (id, name, password_hash, role, tenant_id)
+	VALUES ($1, $2, $3, $4, $5);
+	`
+	if _, err := tx.ExecContext(ctx, query, user.ID, user.Name, user.PasswordHash, role,
+		TenantFromContext(ctx)); err != nil {
+		return err
+	}
+
+	query = `
+	INSERT INTO user_identities (issuer, subject, user_id, email, last_login_at)
+	VALUES ($1, $2, $3, NULLIF($4, ''), now());
+	`
+	if _, err := tx.ExecContext(ctx, query, identity.Issuer, identity.Subject, user.ID, identity.Email); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TouchUserIdentity records a login through a linked provider account and
+// refreshes the email the provider reported for it.
+func TouchUserIdentity(
+	ctx context.Context,
+	db *sql.DB,
+	issuer, subject, email string,
+) error {
+	query := `
+	UPDATE user_identities
+	SET last_login_at = now(), email = NULLIF($3, '')
+	WHERE issuer = $1 AND subject = $2
+		AND user_id IN (SELECT id FROM users WHERE tenant_id = $4);
+	`
+
+	_, err := db.ExecContext(ctx, query, issuer, subject, email, TenantFromContext(ctx))
+	return err
+}
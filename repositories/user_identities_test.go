@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"platform-go-challenge/models"
+)
+
+func TestGetUserIdentity_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT issuer, subject, user_id").
+		WithArgs("https://idp.example.com", "abc", "default").
+		WillReturnError(sql.ErrNoRows)
+
+	identity, err := GetUserIdentity(context.Background(), db, "https://idp.example.com", "abc")
+	if err != nil || identity != nil {
+		t.Fatalf("GetUserIdentity = %+v, %v; want nil, nil", identity, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateUserWithIdentity_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs("sso-1", "Bob", "", models.RoleMember, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_identities").
+		WithArgs("https://idp.example.com", "abc", "sso-1", "bob@example.com").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = CreateUserWithIdentity(context.Background(), db,
+		models.User{ID: "sso-1", Name: "Bob"},
+		models.UserIdentity{Issuer: "https://idp.example.com", Subject: "abc", Email: "bob@example.com"},
+	)
+	if err != nil {
+		t.Fatalf("CreateUserWithIdentity error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCreateUserWithIdentity_RollsBackOnIdentityConflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	conflict := errors.New("duplicate key")
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO users").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO user_identities").
+		WillReturnError(conflict)
+	mock.ExpectRollback()
+
+	err = CreateUserWithIdentity(context.Background(), db,
+		models.User{ID: "sso-1", Name: "Bob"},
+		models.UserIdentity{Issuer: "https://idp.example.com", Subject: "abc"},
+	)
+	if !errors.Is(err, conflict) {
+		t.Fatalf("CreateUserWithIdentity error = %v, want %v", err, conflict)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -3,24 +3,61 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
 	"platform-go-challenge/models"
 )
 
+var (
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when the ID is taken. User IDs are unique
+	// across tenants, as tokens and per-user tables are keyed by them.
+	ErrUserExists = errors.New("user ID already taken")
+)
+
+// Optional profile fields are NULL in the database and empty in models.User.
+const userColumns = `id, name, password_hash, role, disabled_at, COALESCE(email, ''),
+	COALESCE(display_name, ''), COALESCE(avatar_url, ''), COALESCE(locale, ''),
+	created_at, updated_at, last_login_at, email_verified_at, deleted_at, tenant_id`
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Name, &u.PasswordHash, &u.Role, &u.DisabledAt, &u.Email,
+		&u.DisplayName, &u.AvatarURL, &u.Locale, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt,
+		&u.EmailVerifiedAt, &u.DeletedAt, &u.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &u, nil
+}
+
+// CreateUser returns ErrUserExists when the ID is taken, in any tenant.
 func CreateUser(
 	ctx context.Context,
 	db *sql.DB,
 	user models.User,
 ) (string, error) {
 	query := `
-	INSERT INTO users (id, name, password_hash)
-	VALUES ($1, $2, $3)
+	INSERT INTO users (id, name, password_hash, role, email, display_name, avatar_url, locale, tenant_id)
+	VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, ''), $9)
+	ON CONFLICT (id) DO NOTHING
 	RETURNING id;
 	`
 
+	role := user.Role
+	if role == "" {
+		role = models.RoleMember
+	}
+
 	var userID string
-	err := db.QueryRowContext(ctx, query, user.ID, user.Name, user.PasswordHash).Scan(&userID)
+	err := db.QueryRowContext(ctx, query, user.ID, user.Name, user.PasswordHash, role,
+		user.Email, user.DisplayName, user.AvatarURL, user.Locale, TenantFromContext(ctx)).Scan(&userID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrUserExists
+		}
 		return "", err
 	}
 
@@ -33,13 +70,36 @@ func GetUserByID(
 	userID string,
 ) (*models.User, error) {
 	query := `
-	SELECT id, name, password_hash
+	SELECT ` + userColumns + `
+	FROM users
+	WHERE id = $1 AND tenant_id = $2;
+	`
+
+	user, err := scanUser(db.QueryRowContext(ctx, query, userID, TenantFromContext(ctx)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail returns the user with email, ignoring case, or nil when
+// there is none.
+func GetUserByEmail(
+	ctx context.Context,
+	db *sql.DB,
+	email string,
+) (*models.User, error) {
+	query := `
+	SELECT ` + userColumns + `
 	FROM users
-	WHERE id = $1;
+	WHERE lower(email) = lower($1) AND tenant_id = $2;
 	`
 
-	var user models.User
-	err := db.QueryRowContext(ctx, query, userID).Scan(&user.ID, &user.Name, &user.PasswordHash)
+	user, err := scanUser(db.QueryRowContext(ctx, query, email, TenantFromContext(ctx)))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -47,7 +107,7 @@ func GetUserByID(
 		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 func ListUsers(
@@ -55,12 +115,13 @@ func ListUsers(
 	db *sql.DB,
 ) ([]models.User, error) {
 	query := `
-	SELECT id, name, password_hash
+	SELECT ` + userColumns + `
 	FROM users
+	WHERE deleted_at IS NULL AND tenant_id = $1
 	ORDER BY id;
 	`
 
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := db.QueryContext(ctx, query, TenantFromContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -68,11 +129,11 @@ func ListUsers(
 
 	var users []models.User
 	for rows.Next() {
-		var u models.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.PasswordHash); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
-		users = append(users, u)
+		users = append(users, *u)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -81,3 +142,298 @@ func ListUsers(
 
 	return users, nil
 }
+
+func UpdateUserRole(
+	ctx context.Context,
+	db *sql.DB,
+	userID, role string,
+) error {
+	query := `
+	UPDATE users
+	SET role = $2, updated_at = now()
+	WHERE id = $1 AND tenant_id = $3;
+	`
+
+	res, err := db.ExecContext(ctx, query, userID, role, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func UpdateUserPassword(
+	ctx context.Context,
+	db *sql.DB,
+	userID, passwordHash string,
+) error {
+	query := `
+	UPDATE users
+	SET password_hash = $2, updated_at = now()
+	WHERE id = $1 AND tenant_id = $3;
+	`
+
+	res, err := db.ExecContext(ctx, query, userID, passwordHash, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdateUserProfile applies update to userID. Fields left nil are kept and
+// optional fields set to "" are cleared.
+func UpdateUserProfile(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+	update models.UserProfileUpdate,
+) error {
+	query := `
+	UPDATE users
+	SET name = COALESCE($2, name),
+		display_name = CASE WHEN $3::text IS NULL THEN display_name ELSE NULLIF($3, '') END,
+		avatar_url = CASE WHEN $4::text IS NULL THEN avatar_url ELSE NULLIF($4, '') END,
+		locale = CASE WHEN $5::text IS NULL THEN locale ELSE NULLIF($5, '') END,
+		updated_at = now()
+	WHERE id = $1 AND tenant_id = $6;
+	`
+
+	res, err := db.ExecContext(ctx, query, userID, update.Name, update.DisplayName, update.AvatarURL, update.Locale,
+		TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetUserDisabled disables or re-enables userID. Disabling an already
+// disabled user keeps the original disabled_at.
+func SetUserDisabled(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+	disabled bool,
+) error {
+	query := `
+	UPDATE users
+	SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, now()) ELSE NULL END,
+		updated_at = now()
+	WHERE id = $1 AND tenant_id = $3;
+	`
+
+	res, err := db.ExecContext(ctx, query, userID, disabled, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// SetEmailVerified marks email as verified for userID. It returns
+// ErrUserNotFound when the user no longer has that email, ignoring case.
+func SetEmailVerified(
+	ctx context.Context,
+	db *sql.DB,
+	userID, email string,
+) error {
+	query := `
+	UPDATE users
+	SET email_verified_at = COALESCE(email_verified_at, now())
+	WHERE id = $1 AND lower(email) = lower($2) AND tenant_id = $3;
+	`
+
+	res, err := db.ExecContext(ctx, query, userID, email, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// MarkEmailVerificationSent records that a verification email is being sent
+// to userID, unless one was already sent after notBefore. It reports whether
+// the email may be sent.
+func MarkEmailVerificationSent(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+	notBefore time.Time,
+) (bool, error) {
+	query := `
+	UPDATE users
+	SET email_verification_sent_at = now()
+	WHERE id = $1 AND tenant_id = $3
+		AND (email_verification_sent_at IS NULL OR email_verification_sent_at <= $2);
+	`
+
+	res, err := db.ExecContext(ctx, query, userID, notBefore, TenantFromContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// MarkPasswordResetSent records that a password reset email is being sent to
+// userID, unless one was already sent after notBefore. It reports whether the
+// email may be sent.
+func MarkPasswordResetSent(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+	notBefore time.Time,
+) (bool, error) {
+	query := `
+	UPDATE users
+	SET password_reset_sent_at = now()
+	WHERE id = $1 AND tenant_id = $3
+		AND (password_reset_sent_at IS NULL OR password_reset_sent_at <= $2);
+	`
+
+	res, err := db.ExecContext(ctx, query, userID, notBefore, TenantFromContext(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := res.RowsAffected()
+	return rows > 0, err
+}
+
+// SoftDeleteUser marks userID deleted. It returns ErrUserNotFound when the
+// user does not exist or is already deleted.
+func SoftDeleteUser(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) (time.Time, error) {
+	query := `
+	UPDATE users
+	SET deleted_at = now(), updated_at = now()
+	WHERE id = $1 AND deleted_at IS NULL AND tenant_id = $2
+	RETURNING deleted_at;
+	`
+
+	var deletedAt time.Time
+	err := db.QueryRowContext(ctx, query, userID, TenantFromContext(ctx)).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, ErrUserNotFound
+	}
+
+	return deletedAt, err
+}
+
+// RestoreUser undoes the deletion of userID if it was deleted after
+// deletedAfter. It returns ErrUserNotFound when there is no such deletion.
+func RestoreUser(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+	deletedAfter time.Time,
+) error {
+	query := `
+	UPDATE users
+	SET deleted_at = NULL, updated_at = now()
+	WHERE id = $1 AND deleted_at > $2 AND tenant_id = $3;
+	`
+
+	res, err := db.ExecContext(ctx, query, userID, deletedAfter, TenantFromContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	rows, _ := res.RowsAffected()
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently removes the users of the tenant deleted
+// before deletedBefore, and with them their favourites and everything else that
+// references them, returning how many were removed.
+func PurgeDeletedUsers(
+	ctx context.Context,
+	db *sql.DB,
+	deletedBefore time.Time,
+) (int64, error) {
+	query := `
+	DELETE FROM users
+	WHERE deleted_at < $1 AND tenant_id = $2;
+	`
+
+	res, err := db.ExecContext(ctx, query, deletedBefore, TenantFromContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// DeleteUser deletes userID along with everything that references it and
+// returns how many favourites were removed with it.
+func DeleteUser(
+	ctx context.Context,
+	db *sql.DB,
+	userID string,
+) (int64, error) {
+	tx, err := beginUserTx(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	tenantID := TenantFromContext(ctx)
+
+	// Removed explicitly rather than by the cascade so they can be counted
+	res, err := tx.ExecContext(ctx, `DELETE FROM favourites WHERE user_id = $1 AND tenant_id = $2;`, userID, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	favourites, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1 AND tenant_id = $2;`, userID, tenantID)
+	if err != nil {
+		return 0, err
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return 0, ErrUserNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return favourites, nil
+}
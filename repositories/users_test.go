@@ -3,12 +3,24 @@ package repositories
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"platform-go-challenge/models"
 )
 
+var userTestColumns = []string{"id", "name", "password_hash", "role", "disabled_at", "email",
+	"display_name", "avatar_url", "locale", "created_at", "updated_at", "last_login_at", "email_verified_at", "deleted_at", "tenant_id"}
+
+// userRow returns a row for userTestColumns of an enabled user without profile.
+func userRow(id, name, passwordHash, role string) []driver.Value {
+	now := time.Now()
+	return []driver.Value{id, name, passwordHash, role, nil, "", "", "", "", now, now, nil, nil, nil, "default"}
+}
+
 func TestCreateUser_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -19,11 +31,13 @@ func TestCreateUser_Success(t *testing.T) {
 	user := models.User{
 		ID:           "u1",
 		Name:         "Alice",
+		Email:        "alice@example.com",
+		Locale:       "en",
 		PasswordHash: "hashed_pwd",
 	}
 
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs("u1", "Alice", "hashed_pwd").
+		WithArgs("u1", "Alice", "hashed_pwd", "member", "alice@example.com", "", "", "en", "default").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("u1"))
 
 	id, err := CreateUser(context.Background(), db, user)
@@ -39,6 +53,28 @@ func TestCreateUser_Success(t *testing.T) {
 	}
 }
 
+func TestCreateUser_IDTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	// u1 exists in another tenant, so nothing is inserted
+	mock.ExpectQuery("ON CONFLICT \\(id\\) DO NOTHING").
+		WithArgs("u1", "Alice", "", "member", "", "", "", "", "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = CreateUser(WithTenant(context.Background(), "acme"), db, models.User{ID: "u1", Name: "Alice"})
+	if !errors.Is(err, ErrUserExists) {
+		t.Fatalf("err = %v, want ErrUserExists", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestCreateUser_DBError(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -49,7 +85,7 @@ func TestCreateUser_DBError(t *testing.T) {
 	user := models.User{ID: "u1", Name: "Alice"}
 
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs("u1", "Alice", "").
+		WithArgs("u1", "Alice", "", "member", "", "", "", "", "default").
 		WillReturnError(sql.ErrConnDone)
 
 	id, err := CreateUser(context.Background(), db, user)
@@ -73,9 +109,9 @@ func TestGetUserByID_Found(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-			AddRow("u1", "Alice", "hashed"))
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows(userTestColumns).
+			AddRow(userRow("u1", "Alice", "hashed", "member")...))
 
 	user, err := GetUserByID(context.Background(), db, "u1")
 	if err != nil {
@@ -101,7 +137,7 @@ func TestGetUserByID_NotFound(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("missing").
+		WithArgs("missing", "default").
 		WillReturnError(sql.ErrNoRows)
 
 	user, err := GetUserByID(context.Background(), db, "missing")
@@ -125,7 +161,7 @@ func TestGetUserByID_DBError(t *testing.T) {
 	defer db.Close()
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
-		WithArgs("u1").
+		WithArgs("u1", "default").
 		WillReturnError(sql.ErrConnDone)
 
 	user, err := GetUserByID(context.Background(), db, "u1")
@@ -141,6 +177,32 @@ func TestGetUserByID_DBError(t *testing.T) {
 	}
 }
 
+func TestGetUserByEmail_IgnoresCase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	row := userRow("u1", "Alice", "hashed", "member")
+	row[5] = "alice@example.com"
+	mock.ExpectQuery(`WHERE lower\(email\) = lower\(\$1\)`).
+		WithArgs("Alice@Example.com", "default").
+		WillReturnRows(sqlmock.NewRows(userTestColumns).AddRow(row...))
+
+	user, err := GetUserByEmail(context.Background(), db, "Alice@Example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail error: %v", err)
+	}
+	if user == nil || user.ID != "u1" || user.Email != "alice@example.com" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestListUsers_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -148,11 +210,11 @@ func TestListUsers_Success(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "password_hash"}).
-		AddRow("u1", "Alice", "hash1").
-		AddRow("u2", "Bob", "hash2")
+	rows := sqlmock.NewRows(userTestColumns).
+		AddRow(userRow("u1", "Alice", "hash1", "member")...).
+		AddRow(userRow("u2", "Bob", "hash2", "member")...)
 
-	mock.ExpectQuery("SELECT id, name, password_hash").
+	mock.ExpectQuery("FROM users WHERE deleted_at IS NULL").
 		WillReturnRows(rows)
 
 	users, err := ListUsers(context.Background(), db)
@@ -178,7 +240,7 @@ func TestListUsers_Empty(t *testing.T) {
 	}
 	defer db.Close()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "password_hash"})
+	rows := sqlmock.NewRows(userTestColumns)
 
 	mock.ExpectQuery("SELECT id, name, password_hash").
 		WillReturnRows(rows)
@@ -218,3 +280,297 @@ func TestListUsers_DBError(t *testing.T) {
 		t.Fatalf("unmet expectations: %v", err)
 	}
 }
+
+func TestUpdateUserRole_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u2", models.RoleAdmin, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := UpdateUserRole(context.Background(), db, "u2", models.RoleAdmin); err != nil {
+		t.Fatalf("UpdateUserRole error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateUserRole_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("missing", models.RoleMember, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = UpdateUserRole(context.Background(), db, "missing", models.RoleMember)
+	if err != ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateUserPassword_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u1", "new-hash", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := UpdateUserPassword(context.Background(), db, "u1", "new-hash"); err != nil {
+		t.Fatalf("UpdateUserPassword error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestUpdateUserProfile_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	name := "Bob"
+	mock.ExpectExec("UPDATE users").
+		WithArgs("missing", "Bob", nil, nil, nil, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = UpdateUserProfile(context.Background(), db, "missing", models.UserProfileUpdate{Name: &name})
+	if err != ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteUser_ReportsRemovedFavourites(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectUserTx(mock, "u1", models.RoleAdmin)
+	mock.ExpectExec("DELETE FROM favourites").
+		WithArgs("u2", "default").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE FROM users").
+		WithArgs("u2", "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	removed, err := DeleteUser(WithCurrentUser(context.Background(), "u1", models.RoleAdmin), db, "u2")
+	if err != nil {
+		t.Fatalf("DeleteUser error: %v", err)
+	}
+	if removed != 3 {
+		t.Fatalf("removed = %d, want 3", removed)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestDeleteUser_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	expectUserTx(mock, "", "")
+	mock.ExpectExec("DELETE FROM favourites").
+		WithArgs("missing", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM users").
+		WithArgs("missing", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if _, err := DeleteUser(context.Background(), db, "missing"); err != ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSetUserDisabled_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("missing", true, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := SetUserDisabled(context.Background(), db, "missing", true); err != ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSetEmailVerified_EmailChanged(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u1", "old@example.com", "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = SetEmailVerified(context.Background(), db, "u1", "old@example.com")
+	if err != ErrUserNotFound {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestMarkEmailVerificationSent_RateLimited(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	notBefore := time.Now().Add(-time.Minute)
+	mock.ExpectExec("UPDATE users").
+		WithArgs("u1", notBefore, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ok, err := MarkEmailVerificationSent(context.Background(), db, "u1", notBefore)
+	if err != nil {
+		t.Fatalf("MarkEmailVerificationSent error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected a recent email to block sending")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestMarkPasswordResetSent_RateLimited(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	notBefore := time.Now().Add(-time.Minute)
+	mock.ExpectExec("SET password_reset_sent_at").
+		WithArgs("u1", notBefore, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	ok, err := MarkPasswordResetSent(context.Background(), db, "u1", notBefore)
+	if err != nil {
+		t.Fatalf("MarkPasswordResetSent error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected a recent email to block sending")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestSoftDeleteUser_AlreadyDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("UPDATE users").
+		WithArgs("u1", "default").
+		WillReturnRows(sqlmock.NewRows([]string{"deleted_at"}))
+
+	if _, err := SoftDeleteUser(context.Background(), db, "u1"); err != ErrUserNotFound {
+		t.Fatalf("err = %v, want ErrUserNotFound", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestRestoreUser_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	deletedAfter := time.Now().Add(-time.Hour)
+	mock.ExpectExec("SET deleted_at = NULL").
+		WithArgs("u1", deletedAfter, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := RestoreUser(context.Background(), db, "u1", deletedAfter); err != nil {
+		t.Fatalf("RestoreUser error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPurgeDeletedUsers_Success(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New error: %v", err)
+	}
+	defer db.Close()
+
+	deletedBefore := time.Now().Add(-time.Hour)
+	mock.ExpectExec("DELETE FROM users").
+		WithArgs(deletedBefore, "default").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	purged, err := PurgeDeletedUsers(context.Background(), db, deletedBefore)
+	if err != nil {
+		t.Fatalf("PurgeDeletedUsers error: %v", err)
+	}
+	if purged != 2 {
+		t.Fatalf("purged = %d, want 2", purged)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test secret ("12345678901234567890") and SHA-1 vectors,
+// truncated to the 6 digits used here.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, v := range vectors {
+		got, err := Code(rfcSecret, Step(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(%d) error: %v", v.unix, err)
+		}
+		if got != v.code {
+			t.Fatalf("Code(%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestValidate_AllowsSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	prev, _ := Code(rfcSecret, Step(now)-1)
+	step, ok := Validate(rfcSecret, prev, now)
+	if !ok || step != Step(now)-1 {
+		t.Fatalf("Validate(previous step) = %d, %v; want %d, true", step, ok, Step(now)-1)
+	}
+
+	old, _ := Code(rfcSecret, Step(now)-2)
+	if _, ok := Validate(rfcSecret, old, now); ok {
+		t.Fatal("expected code two steps old to be rejected")
+	}
+}
+
+func TestValidate_RejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := Validate(rfcSecret, code, now); ok {
+			t.Fatalf("Validate(%q) accepted", code)
+		}
+	}
+
+	if _, ok := Validate(rfcSecret, "287 082", now); !ok {
+		t.Fatal("expected spaces in code to be ignored")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret error: %v", err)
+	}
+	b, _ := GenerateSecret()
+
+	if len(a) != 32 || a == b {
+		t.Fatalf("unexpected secrets %q, %q", a, b)
+	}
+	if _, err := Code(a, 1); err != nil {
+		t.Fatalf("generated secret does not decode: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Platform", "u1", "JBSWY3DPEHPK3PXP")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/Platform:u1?") {
+		t.Fatalf("unexpected URI: %s", uri)
+	}
+	for _, part := range []string{"secret=JBSWY3DPEHPK3PXP", "issuer=Platform", "digits=6", "period=30"} {
+		if !strings.Contains(uri, part) {
+			t.Fatalf("URI %s missing %s", uri, part)
+		}
+	}
+}